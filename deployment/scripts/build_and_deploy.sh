@@ -13,7 +13,7 @@ make build-for-lambda
 
 # Create deployment package
 cd "${BUILD_DIR}"
-zip -r lambda.zip bootstrap index.bleve
+zip -r lambda.zip bootstrap bundle
 
 # Get terraform outputs
 cd "${TF_DIR}"
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hf-api/src/internal/config"
+	"hf-api/src/internal/data"
+	"hf-api/src/internal/logging"
+	"hf-api/src/internal/query"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// benchindex [-db PATH] [-index PATH] [-bundle DIR] [runs]
+//
+// Compares the index modes on the configured database. Every run starts a
+// fresh process per mode, so startup is measured cold: decoding the
+// database, then opening or building the index. Each process then runs
+// sampleQueries a few times and reports its memory once the index is loaded.
+// The number of results of every query is compared across modes, which
+// must all find the same cards.
+//
+// Memory is what the Go runtime holds. Segments of the on-disk index are
+// memory-mapped and not included, but they are part of the artifact, whose
+// size is reported separately.
+func main() {
+	logging.Setup()
+
+	cfg, args := config.Parse(config.Defaults())
+
+	if mode := os.Getenv(childEnv); mode != "" {
+		cfg.IndexMode = mode
+		runChild(cfg)
+		return
+	}
+
+	runs := 5
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			logging.Fatal("invalid number of runs", "value", args[0])
+		}
+		runs = n
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "mode\tprocess\tdb\tindex\tper query\theap\tsys\tartifact\t")
+
+	var reference result
+	mismatches := []string{}
+
+	for _, mode := range config.IndexModes {
+		results := make([]result, runs)
+
+		for i := range results {
+			r, err := runParent(mode)
+			if err != nil {
+				logging.Fatal("benchmark failed", "mode", mode, "error", err)
+			}
+			results[i] = r
+		}
+
+		if reference.Totals == nil {
+			reference = results[0]
+		}
+		for i, q := range sampleQueries {
+			if results[0].Totals[i] != reference.Totals[i] {
+				mismatches = append(mismatches, fmt.Sprintf("%s: %q found %d cards, %s found %d", mode, q, results[0].Totals[i], config.IndexModes[0], reference.Totals[i]))
+			}
+		}
+
+		m := medians(results)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			mode,
+			m.Process.Round(time.Millisecond),
+			m.Database.Round(time.Millisecond),
+			m.Index.Round(time.Millisecond),
+			(m.Queries / time.Duration(len(sampleQueries)*queryPasses)).Round(time.Microsecond),
+			formatBytes(m.HeapBytes),
+			formatBytes(m.SysBytes),
+			formatBytes(artifactSize(cfg, mode)),
+		)
+	}
+
+	w.Flush()
+	fmt.Printf("\nmedians of %d runs; process is the wall time of a child, from exec to exit\n", runs)
+
+	if len(mismatches) > 0 {
+		fmt.Println("\nresults differ between modes:")
+		for _, m := range mismatches {
+			fmt.Println("  " + m)
+		}
+		os.Exit(1)
+	}
+}
+
+// childEnv tells a child process which mode to measure.
+const childEnv = "BENCHINDEX_MODE"
+
+// sampleQueries is a mix of the searches people run: names, types and
+// rules text, combined with colours, numbers, keywords and flags, plus
+// negations, alternatives and side-aware queries.
+var sampleQueries = []string{
+	"goblin",
+	"dragon",
+	`!"goblin guide"`,
+	"t:creature",
+	"t:creature pow>=3",
+	`t:"legendary creature"`,
+	"t:instant c:u",
+	"kw:flying",
+	"kw:flying t:creature mv<=3",
+	"c=wu",
+	"c<=rg t:creature",
+	"c:colorless -t:land",
+	"mv>=6",
+	"o:destroy",
+	`o:"draw a card"`,
+	"o:draw -t:creature",
+	"(t:instant or t:sorcery) mv<=2",
+	"set:HC1 creator:Leslie",
+	"has:rulings",
+	"is:token",
+	"ruling:trigger",
+	"side:(t:creature t:artifact)",
+	"side:(mv>=3 pow<2)",
+}
+
+// queryPasses is how many times each process runs sampleQueries.
+const queryPasses = 20
+
+type result struct {
+	Process   time.Duration `json:"-"`
+	Database  time.Duration `json:"database"`
+	Index     time.Duration `json:"index"`
+	Queries   time.Duration `json:"queries"`
+	HeapBytes uint64        `json:"heap_bytes"`
+	SysBytes  uint64        `json:"sys_bytes"`
+	// Totals is the number of results of each sample query
+	Totals []int `json:"totals"`
+}
+
+func runParent(mode string) (result, error) {
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Env = append(os.Environ(), childEnv+"="+mode)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		return result{}, fmt.Errorf("%v\n%s", err, stderr.String())
+	}
+	elapsed := time.Since(start)
+
+	// Logs go to stderr, the result is all of stdout
+	var r result
+	if err := json.Unmarshal(stdout.Bytes(), &r); err != nil {
+		return result{}, fmt.Errorf("invalid child output: %v", err)
+	}
+	r.Process = elapsed
+
+	return r, nil
+}
+
+func runChild(cfg config.Config) {
+	store, err := data.Load(cfg)
+	if err != nil {
+		logging.Fatal("failed to load the store", "error", err)
+	}
+
+	timings := store.LoadTimings()
+	r := result{Database: timings["database"], Index: timings["index"]}
+
+	roots := make([]query.Node, len(sampleQueries))
+	for i, q := range sampleQueries {
+		root, _, err := query.Parse(q)
+		if err != nil || root == nil {
+			logging.Fatal("invalid sample query", "query", q)
+		}
+		roots[i] = root
+	}
+
+	r.Totals = make([]int, len(roots))
+
+	start := time.Now()
+	for range queryPasses {
+		for i, root := range roots {
+			results, err := store.Search(context.Background(), data.SearchRequest{
+				Query: root,
+				Order: data.OrderRelevance,
+				Size:  10,
+			})
+			if err != nil {
+				logging.Fatal("search failed", "query", sampleQueries[i], "error", err)
+			}
+			r.Totals[i] = results.Total
+		}
+	}
+	r.Queries = time.Since(start)
+
+	runtime.GC()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	r.HeapBytes = mem.HeapInuse
+	r.SysBytes = mem.Sys
+
+	out, _ := json.Marshal(r)
+	fmt.Println(string(out))
+}
+
+// medians returns the median of each measure, separately.
+func medians(results []result) result {
+	median := func(value func(r result) int64) int64 {
+		values := make([]int64, len(results))
+		for i, r := range results {
+			values[i] = value(r)
+		}
+		slices.Sort(values)
+		return values[len(values)/2]
+	}
+
+	return result{
+		Process:   time.Duration(median(func(r result) int64 { return int64(r.Process) })),
+		Database:  time.Duration(median(func(r result) int64 { return int64(r.Database) })),
+		Index:     time.Duration(median(func(r result) int64 { return int64(r.Index) })),
+		Queries:   time.Duration(median(func(r result) int64 { return int64(r.Queries) })),
+		HeapBytes: uint64(median(func(r result) int64 { return int64(r.HeapBytes) })),
+		SysBytes:  uint64(median(func(r result) int64 { return int64(r.SysBytes) })),
+	}
+}
+
+// artifactSize is what a mode needs shipped next to the binary: the database,
+// and the index when it is opened from disk.
+func artifactSize(cfg config.Config, mode string) uint64 {
+	var size uint64
+
+	paths := []string{cfg.DBPath}
+	if mode == config.IndexOnDisk {
+		paths = append(paths, cfg.IndexPath)
+	}
+
+	for _, path := range paths {
+		filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				size += uint64(info.Size())
+			}
+			return nil
+		})
+	}
+
+	return size
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -1,15 +1,17 @@
 package main
 
 import (
-	"hf-api/src/internal/app/api"
-	"log"
+	"hf-api/src/internal/logging"
+	"hf-api/src/internal/query"
 	"os"
 	"path/filepath"
 )
 
 func main() {
+	logging.Setup()
+
 	if len(os.Args) < 2 {
-		log.Fatalf("output file required")
+		logging.Fatal("output file required")
 	}
 
 	outputFile := os.Args[1]
@@ -19,12 +21,12 @@ func main() {
 	}
 
 	if _, err := filepath.Abs(outputFile); err != nil {
-		log.Fatalf("malformed path: %v", err)
+		logging.Fatal("malformed path", "error", err)
 	}
 
 	allTokenAliases := map[string]string{}
 
-	for k, v := range api.KnownTokens {
+	for k, v := range query.KnownTokens {
 		for _, alias := range v {
 			allTokenAliases[alias] = k
 		}
@@ -33,13 +35,13 @@ func main() {
 	file, err := os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE, 0644)
 
 	if err != nil {
-		log.Fatalf("failed to open file for write: %v", err)
+		logging.Fatal("failed to open file for write", "error", err)
 	}
 
 	defer file.Close()
 
 	file.WriteString("// Code generated by codegens/codegens.go; DO NOT EDIT.\n\n")
-	file.WriteString("package api\n\n")
+	file.WriteString("package query\n\n")
 	file.WriteString("var TokenAliasMap = map[string]string{\n")
 	for alias, token := range allTokenAliases {
 		file.WriteString("\t\"" + alias + "\": \"" + token + "\",\n")
@@ -1,80 +1,135 @@
 package main
 
 import (
+	"crypto/sha256"
 	_ "embed"
 	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
+	"hf-api/src/internal/bundle"
+	"hf-api/src/internal/config"
+	"hf-api/src/internal/indexing"
+	"hf-api/src/internal/logging"
 	"hf-api/src/pkg/cards"
 	"hf-api/src/pkg/hellfall"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"strconv"
+	"time"
 
 	"github.com/blevesearch/bleve/v2"
-	"github.com/blevesearch/bleve/v2/mapping"
 )
 
-const batchSize = 500
-const dbGobFilename = "db.gob.bin"
-const indexName = "index.bleve"
-
+// gendb [-db PATH] [-index PATH] <source> <destination dir> [keywords file]
+//
+// Writes a bundle to the destination directory: the database, its index and
+// the manifest describing them. -db/DB_PATH and -index/INDEX_PATH place the
+// database (with its manifest) and the index elsewhere.
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatalf("missing source path argument")
+	logging.Setup()
+
+	cfg, args := config.Parse(config.Config{IndexMode: config.IndexOnDisk})
+
+	if len(args) < 1 {
+		logging.Fatal("missing source path argument")
+	}
+
+	if len(args) < 2 {
+		logging.Fatal("missing destination path argument")
+	}
+
+	sourcePath := args[0]
+	destPath := args[1]
+
+	if cfg.DBPath == "" {
+		cfg.DBPath = filepath.Join(destPath, bundle.DBName)
 	}
 
-	if len(os.Args) < 3 {
-		log.Fatalf("missing destination path argument")
+	if cfg.IndexPath == "" {
+		cfg.IndexPath = filepath.Join(destPath, bundle.IndexName)
 	}
 
-	sourcePath := os.Args[1]
-	destPath := os.Args[2]
+	// Optional list of Hellscube-invented keywords, one per line
+	var customKeywords []string
+
+	if len(args) > 2 {
+		keywordsFile, err := os.Open(args[2])
+
+		if err != nil {
+			logging.Fatal("failed to open keywords file", "error", err)
+		}
+
+		customKeywords, err = hellfall.ReadKeywordList(keywordsFile)
+		keywordsFile.Close()
+
+		if err != nil {
+			logging.Fatal("failed to read keywords file", "error", err)
+		}
+	}
 
 	if _, err := filepath.Abs(sourcePath); err != nil {
-		log.Fatalf("malformed path: %v", err)
+		logging.Fatal("malformed path", "error", err)
 	}
 
 	if _, err := filepath.Abs(destPath); err != nil {
-		log.Fatalf("malformed path: %v", err)
+		logging.Fatal("malformed path", "error", err)
 	}
 
 	var dbJSON hellfall.Root
 
-	sourceFile, err := os.Open(sourcePath)
+	source, err := os.ReadFile(sourcePath)
 
 	if err != nil {
-		log.Fatalf("failed to open source file: %v", err)
+		logging.Fatal("failed to read source file", "error", err)
 	}
 
-	defer sourceFile.Close()
+	if err := json.Unmarshal(source, &dbJSON); err != nil {
+		logging.Fatal("decode json from stdin", "error", err)
+	}
 
-	dec := json.NewDecoder(sourceFile)
-	if err := dec.Decode(&dbJSON); err != nil {
-		log.Fatalf("decode json from stdin: %v", err)
+	db := hellfall.NormaliseDB(&dbJSON, hellfall.NewKeywordExtractor(customKeywords))
+
+	hellfall.AssignIDs(db)
+
+	for _, warning := range hellfall.ResolveParts(db) {
+		slog.Warn(warning)
 	}
 
-	db := hellfall.NormaliseDB(&dbJSON)
+	db, tokens := hellfall.BuildTokens(db)
+
+	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0o755); err != nil {
+		logging.Fatal("failed to create destination", "error", err)
+	}
 
-	if err := writeDB(filepath.Join(destPath, dbGobFilename), db); err != nil {
-		log.Fatalf("failed to encode gob: %v", err)
+	if err := writeDB(cfg.DBPath, &cards.Database{Cards: db, Tokens: tokens}); err != nil {
+		logging.Fatal("failed to encode gob", "error", err)
 	}
 
-	wd, err := os.Getwd()
+	mappingHash, err := generateIndex(cfg.IndexPath, db)
 	if err != nil {
-		log.Fatalf("failed to get working directory: %v", err)
+		logging.Fatal("failed to generate index", "error", err)
 	}
 
-	if err := generateIndex(filepath.Join(wd, indexName), db); err != nil {
-		log.Fatalf("failed to generate index: %v", err)
+	sourceSum := sha256.Sum256(source)
+	manifest := &bundle.Manifest{
+		SchemaVersion: bundle.SchemaVersion,
+		SourceSHA256:  hex.EncodeToString(sourceSum[:]),
+		Cards:         len(db),
+		GeneratedAt:   time.Now().UTC(),
+		MappingHash:   mappingHash,
+	}
+
+	// Written last, so a bundle with a manifest is complete
+	if err := manifest.Write(cfg.DBPath); err != nil {
+		logging.Fatal("failed to write manifest", "error", err)
 	}
 }
 
-func writeDB(destPath string, db []cards.Card) error {
+func writeDB(destPath string, db *cards.Database) error {
 	destFile, err := os.Create(destPath)
 
 	if err != nil {
-		log.Fatalf("failed to open file for write: %v", err)
+		logging.Fatal("failed to open file for write", "error", err)
 	}
 
 	defer destFile.Close()
@@ -83,84 +138,35 @@ func writeDB(destPath string, db []cards.Card) error {
 	return enc.Encode(db)
 }
 
-func generateIndex(indexPath string, db []cards.Card) error {
+// generateIndex indexes the cards at indexPath and returns the hash of the
+// index mapping, for the manifest.
+func generateIndex(indexPath string, db []cards.Card) (string, error) {
 	os.RemoveAll(indexPath)
 
-	mapping := buildMapping()
+	mapping, err := indexing.BuildMapping()
+	if err != nil {
+		return "", err
+	}
+
 	index, err := bleve.New(indexPath, mapping)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	batch := index.NewBatch()
-	for i, c := range db {
-		docID := strconv.Itoa(i)
-		batch.Index(docID, &c)
-
-		if (i+1)%batchSize == 0 {
-			_ = index.Batch(batch)
-			batch = index.NewBatch()
-		}
+	if err := indexing.Index(index, db); err != nil {
+		index.Close()
+		return "", err
 	}
-	if batch.Size() > 0 {
-		_ = index.Batch(batch)
+
+	mappingHash, err := bundle.MappingHash(index.Mapping())
+	if err != nil {
+		return "", err
 	}
 
 	if err := index.Close(); err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
-}
-
-func buildMapping() *mapping.IndexMappingImpl {
-	m := bleve.NewIndexMapping()
-	m.DefaultAnalyzer = "en"
-
-	doc := bleve.NewDocumentMapping()
-
-	subDoc := bleve.NewDocumentMapping()
-	doc.AddSubDocumentMapping("sides", subDoc)
-
-	// Text fields
-	text := bleve.NewTextFieldMapping()
-	doc.AddFieldMappingsAt("name", text)
-
-	// Keyword fields
-	keyword := bleve.NewKeywordFieldMapping()
-	doc.AddFieldMappingsAt("creator", keyword)
-	doc.AddFieldMappingsAt("set", keyword)
-	doc.AddFieldMappingsAt("legality", keyword)
-	doc.AddFieldMappingsAt("colors", keyword)
-	doc.AddFieldMappingsAt("tags", keyword)
-	doc.AddFieldMappingsAt("mv_original", keyword)
-
-	// Numeric fields
-	num := bleve.NewNumericFieldMapping()
-	doc.AddFieldMappingsAt("mv", num)
-
-	// Text fields for sides
-	subDoc.AddFieldMappingsAt("cost", text)
-	subDoc.AddFieldMappingsAt("supertypes", text)
-	subDoc.AddFieldMappingsAt("card_types", text)
-	subDoc.AddFieldMappingsAt("subtypes", text)
-	subDoc.AddFieldMappingsAt("textbox", text)
-	subDoc.AddFieldMappingsAt("flavor_text", text)
-
-	// Keyword fields for sides
-	subDoc.AddFieldMappingsAt("mv_original", keyword)
-	subDoc.AddFieldMappingsAt("power_original", keyword)
-	subDoc.AddFieldMappingsAt("toughness_original", keyword)
-	subDoc.AddFieldMappingsAt("loyalty_original", keyword)
-
-	// Numeric fields for sides
-	subDoc.AddFieldMappingsAt("mv", num)
-	subDoc.AddFieldMappingsAt("power", num)
-	subDoc.AddFieldMappingsAt("toughness", num)
-	subDoc.AddFieldMappingsAt("loyalty", num)
-
-	m.DefaultMapping = doc
-
-	return m
+	return mappingHash, nil
 }
@@ -1,23 +1,80 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"hf-api/src/internal/app/api"
+	"hf-api/src/internal/bundle"
+	"hf-api/src/internal/config"
 	"hf-api/src/internal/data"
-	"log"
+	"hf-api/src/internal/logging"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	err := data.LoadDB()
+	logging.Setup()
+
+	// The bundle can be replaced while the server runs. Debug searches are on
+	// by default locally.
+	defaults := config.Defaults()
+	defaults.AllowDebug = true
+	cfg, _ := config.Parse(defaults)
+
+	store, err := data.NewReloadableStore(func() (data.LoadedStore, error) {
+		return data.Load(cfg)
+	})
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("failed to load the database", "error", err)
+	}
+
+	go reloadOnSignal(store)
+
+	if cfg.ReloadInterval > 0 {
+		paths := []string{cfg.DBPath, bundle.ManifestPath(cfg.DBPath)}
+		if cfg.IndexMode == config.IndexOnDisk {
+			paths = append(paths, cfg.IndexPath)
+		}
+		go data.WatchFiles(context.Background(), cfg.ReloadInterval, paths, func() { reload(store, "files changed") })
 	}
-	runServer()
+
+	runServer(cfg, data.WithCache(store, cfg.CacheSize, cfg.CacheTTL))
 }
 
-func runServer() {
-	handler := api.NewRouterHandler()
-	log.Default().Println("Starting HTTP server on :8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+// reloadOnSignal reloads the database on every SIGHUP.
+func reloadOnSignal(store *data.ReloadableStore) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		reload(store, "SIGHUP")
+	}
+}
+
+func reload(store *data.ReloadableStore, reason string) {
+	stats, err := store.Reload()
+	if err != nil {
+		slog.Error("reload failed, keeping the current data", "reason", reason, "error", err)
+		return
+	}
+	slog.Info("reloaded", "reason", reason, "cards", stats.Cards, "version", stats.Version)
+}
+
+func runServer(cfg config.Config, store data.CardStore) {
+	imageBaseURL, err := api.ParseImageBaseURL(cfg.ImageBaseURL)
+	if err != nil {
+		logging.Fatal("invalid IMAGE_BASE_URL", "error", err)
+	}
+
+	handler := api.NewRouterHandler(store, api.Config{
+		AllowDebug:   cfg.AllowDebug,
+		ImageBaseURL: imageBaseURL,
+		MaxAge:       cfg.CacheMaxAge,
+		AdminToken:   cfg.AdminToken,
+	})
+	slog.Info("starting HTTP server", "addr", cfg.ListenAddr)
+	logging.Fatal("HTTP server stopped", "error", http.ListenAndServe(cfg.ListenAddr, handler))
 }
@@ -1,22 +1,37 @@
 package main
 
 import (
-	"log"
-
 	"hf-api/src/internal/app/api"
+	"hf-api/src/internal/config"
 	"hf-api/src/internal/data"
+	"hf-api/src/internal/logging"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
 )
 
 func main() {
-	err := data.LoadDB()
+	logging.Setup()
+
+	cfg, _ := config.Parse(config.Defaults())
+
+	store, err := data.Load(cfg)
+	if err != nil {
+		logging.Fatal("failed to load the database", "error", err)
+	}
+
+	imageBaseURL, err := api.ParseImageBaseURL(cfg.ImageBaseURL)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("invalid IMAGE_BASE_URL", "error", err)
 	}
 
-	handler := api.NewRouterHandler()
+	cached := data.WithCache(store, cfg.CacheSize, cfg.CacheTTL)
+	handler := api.NewRouterHandler(cached, api.Config{
+		AllowDebug:   cfg.AllowDebug,
+		ImageBaseURL: imageBaseURL,
+		MaxAge:       cfg.CacheMaxAge,
+		AdminToken:   cfg.AdminToken,
+	})
 	adapter := httpadapter.NewV2(handler)
 	lambda.Start(adapter.ProxyWithContext)
 }
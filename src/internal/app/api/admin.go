@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"fmt"
+	"hf-api/src/internal/data"
+	"net/http"
+	"strings"
+)
+
+// authorizeAdmin checks the bearer token of an admin request, returning the
+// response to send when it is refused.
+func (rt *router) authorizeAdmin(req *http.Request) *APIResponse {
+	if rt.config.AdminToken == "" {
+		return &APIResponse{
+			Code:  http.StatusForbidden,
+			Error: &APIError{Message: "Admin endpoints are disabled"},
+		}
+	}
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(rt.config.AdminToken)) != 1 {
+		return &APIResponse{
+			Code:   http.StatusUnauthorized,
+			Error:  &APIError{Message: "Invalid admin token"},
+			Header: http.Header{"Www-Authenticate": {"Bearer"}},
+		}
+	}
+
+	return nil
+}
+
+// /admin/reload
+
+// ReloadResponse describes the data served after a reload.
+type ReloadResponse struct {
+	Status string     `json:"status"`
+	Stats  data.Stats `json:"stats"`
+}
+
+// reload loads the database again and swaps it in. Requests in flight finish
+// on the previous data.
+func (rt *router) reload(ctx context.Context, req *http.Request) *APIResponse {
+	if res := rt.authorizeAdmin(req); res != nil {
+		return res
+	}
+
+	reloader, ok := rt.store.(data.Reloader)
+	if !ok {
+		return &APIResponse{
+			Code:  http.StatusNotImplemented,
+			Error: &APIError{Message: "This store can't be reloaded"},
+		}
+	}
+
+	stats, err := reloader.Reload()
+
+	if errors.Is(err, data.ErrReloadUnsupported) {
+		return &APIResponse{
+			Code:  http.StatusNotImplemented,
+			Error: &APIError{Message: "This store can't be reloaded"},
+		}
+	}
+
+	if errors.Is(err, data.ErrReloadInProgress) {
+		return &APIResponse{
+			Code:  http.StatusConflict,
+			Error: &APIError{Message: "A reload is already in progress"},
+		}
+	}
+
+	if err != nil {
+		return &APIResponse{
+			Code:  http.StatusInternalServerError,
+			Error: wrapError("Reload failed, the previous data is still served: "+err.Error(), err),
+		}
+	}
+
+	return &APIResponse{
+		Code:    http.StatusOK,
+		Content: &ReloadResponse{Status: "reloaded", Stats: stats},
+	}
+}
+
+// /admin/metrics
+
+// metrics returns the variables published with expvar, including the search
+// cache counters, in the format of expvar's own handler.
+func (rt *router) metrics(ctx context.Context, req *http.Request) *APIResponse {
+	if res := rt.authorizeAdmin(req); res != nil {
+		return res
+	}
+
+	var body bytes.Buffer
+	body.WriteString("{")
+	expvar.Do(func(kv expvar.KeyValue) {
+		if body.Len() > 1 {
+			body.WriteString(",")
+		}
+		fmt.Fprintf(&body, "\n%q: %s", kv.Key, kv.Value)
+	})
+	body.WriteString("\n}\n")
+
+	return &APIResponse{
+		Code:   http.StatusOK,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   body.Bytes(),
+	}
+}
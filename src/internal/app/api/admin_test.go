@@ -0,0 +1,64 @@
+package api
+
+import (
+	"hf-api/src/internal/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// loadedStore makes a MemoryStore reloadable.
+type loadedStore struct {
+	*data.MemoryStore
+}
+
+func (loadedStore) Close() error                          { return nil }
+func (loadedStore) LoadTimings() map[string]time.Duration { return nil }
+
+func TestAdminReload(t *testing.T) {
+	reloadable, err := data.NewReloadableStore(func() (data.LoadedStore, error) {
+		return loadedStore{testHandlerStore().(*data.MemoryStore)}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		store         data.CardStore
+		token         string
+		authorization string
+		code          int
+	}{
+		{"disabled", reloadable, "", "", http.StatusForbidden},
+		{"disabled with a token", reloadable, "", "Bearer secret", http.StatusForbidden},
+		{"no token", reloadable, "secret", "", http.StatusUnauthorized},
+		{"wrong token", reloadable, "secret", "Bearer guess", http.StatusUnauthorized},
+		{"not a bearer token", reloadable, "secret", "secret", http.StatusUnauthorized},
+		{"not reloadable", testHandlerStore(), "secret", "Bearer secret", http.StatusNotImplemented},
+		{"reloaded", reloadable, "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewRouterHandler(tt.store, Config{AdminToken: tt.token})
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/admin/reload", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("returned %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+
+			if tt.code == http.StatusUnauthorized && rec.Header().Get("Www-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q, want Bearer", rec.Header().Get("Www-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uncached lists the GET routes whose responses must not be cached. Every
+// other GET route only depends on the data and the request, so it gets
+// validators and a Cache-Control.
+var uncached = map[string]bool{
+	"GET /health":        true,
+	"GET /admin/metrics": true,
+}
+
+func cacheable(pattern string) bool {
+	return strings.HasPrefix(pattern, "GET ") && !uncached[pattern]
+}
+
+// validators returns the caching headers of a response to r, known before
+// it is computed: a weak ETag of the data version, the request and the
+// settings that change responses, the date the data was generated, and the
+// Cache-Control from Config.MaxAge. Debug responses carry the timings of
+// their request, so they are only sent with no-store.
+func (rt *router) validators(r *http.Request) http.Header {
+	if r.URL.Query().Get("debug") == "true" {
+		return http.Header{"Cache-Control": {"no-store"}}
+	}
+
+	stats := rt.storeFor(r.Context()).Stats()
+
+	imageBaseURL := ""
+	if rt.config.ImageBaseURL != nil {
+		imageBaseURL = rt.config.ImageBaseURL.String()
+	}
+
+	// The parameters are sorted, so their order doesn't matter
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		stats.Version,
+		r.URL.Path,
+		r.URL.Query().Encode(),
+		r.Header.Get("Accept"),
+		imageBaseURL,
+	}, "\x00")))
+
+	header := http.Header{
+		"Etag": {`W/"` + hex.EncodeToString(sum[:12]) + `"`},
+		// The format is negotiated from Accept
+		"Vary": {"Accept"},
+	}
+
+	if !stats.GeneratedAt.IsZero() {
+		header.Set("Last-Modified", stats.GeneratedAt.UTC().Format(http.TimeFormat))
+	}
+
+	if maxAge := int(rt.config.MaxAge / time.Second); maxAge > 0 {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+
+	return header
+}
+
+// notModified reports whether the client already has the response described
+// by validators. If-Modified-Since is only looked at without If-None-Match.
+func notModified(r *http.Request, validators http.Header) bool {
+	if validators.Get("Etag") == "" {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, validators.Get("Etag"))
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(validators.Get("Last-Modified"))
+	return err == nil && !modified.After(ims)
+}
+
+// etagMatches compares ETags weakly, as If-None-Match does.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutValidators removes the caching headers from a response that turned
+// out to be an error.
+func withoutValidators(w http.ResponseWriter) {
+	for _, key := range []string{"Etag", "Last-Modified", "Cache-Control", "Vary"} {
+		w.Header().Del(key)
+	}
+}
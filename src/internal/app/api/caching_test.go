@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serve(t *testing.T, handler http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNotModified(t *testing.T) {
+	handler := testHandler()
+
+	first := serve(t, handler, "/v1/cards/search?q=goblin", nil)
+	etag := first.Header().Get("Etag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("search returned %d with ETag %q", first.Code, etag)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		code   int
+	}{
+		{"matching ETag", "/v1/cards/search?q=goblin", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"other ETag", "/v1/cards/search?q=goblin", http.Header{"If-None-Match": {`W/"other"`}}, http.StatusOK},
+		{"not found", "/v1/cards/nope", http.Header{"If-None-Match": {"*"}}, http.StatusNotFound},
+		{"invalid query", "/v1/cards/search?q=goblin((", http.Header{"If-None-Match": {"*"}}, http.StatusBadRequest},
+		{"invalid fields", "/v1/cards/search?q=goblin&fields=nope", http.Header{"If-None-Match": {"*"}}, http.StatusBadRequest},
+		{"debug disabled", "/v1/cards/search?q=goblin&debug=true", http.Header{"If-None-Match": {"*"}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, handler, tt.target, tt.header); rec.Code != tt.code {
+				t.Errorf("returned %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestDebugNotStored(t *testing.T) {
+	handler := NewRouterHandler(testHandlerStore(), Config{AllowDebug: true, MaxAge: time.Minute})
+
+	rec := serve(t, handler, "/v1/cards/search?q=goblin&debug=true", http.Header{"If-None-Match": {"*"}})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("debug search returned %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control is %q, want no-store", got)
+	}
+	if got := rec.Header().Get("Etag"); got != "" {
+		t.Errorf("debug response has ETag %q", got)
+	}
+}
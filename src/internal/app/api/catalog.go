@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Catalog is a sorted list of distinct values, shaped after Scryfall's
+// catalog object.
+type Catalog struct {
+	Object      string   `json:"object"`
+	TotalValues int      `json:"total_values"`
+	Data        []string `json:"data"`
+}
+
+func newCatalog(values []string) *Catalog {
+	seen := map[string]struct{}{}
+	data := []string{}
+
+	for _, v := range values {
+		key := strings.ToLower(v)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		data = append(data, v)
+	}
+
+	sort.Slice(data, func(i, j int) bool {
+		return strings.ToLower(data[i]) < strings.ToLower(data[j])
+	})
+
+	return &Catalog{
+		Object:      "catalog",
+		TotalValues: len(data),
+		Data:        data,
+	}
+}
+
+// /catalog/keywords
+
+func (rt *router) keywordCatalog(ctx context.Context, req *http.Request) *APIResponse {
+	keywords := []string{}
+
+	for _, card := range rt.storeFor(ctx).All() {
+		keywords = append(keywords, card.Keywords...)
+	}
+
+	return &APIResponse{
+		Code:    http.StatusOK,
+		Content: newCatalog(keywords),
+	}
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hf-api/src/pkg/cards"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder writes response content in a single media type. Encoders other
+// than JSON only receive cardContent. An *APIError returned before anything
+// was written is sent to the client instead.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, params url.Values, content any) error
+}
+
+// cardContent is implemented by responses made of cards, which can be sent
+// in any registered format. Other responses are only available as JSON.
+type cardContent interface {
+	cardItems() []CardResult
+}
+
+func (l *CardList) cardItems() []CardResult { return l.Data }
+func (r *CardResult) cardItems() []CardResult {
+	return []CardResult{*r}
+}
+
+const defaultFormat = "json"
+
+var encoders = map[string]Encoder{}
+var formatsByMediaType = map[string]string{}
+
+// RegisterEncoder makes a format available through `format=<name>` and
+// through the Accept header, using the encoder's media type.
+func RegisterEncoder(name string, e Encoder) {
+	encoders[name] = e
+
+	mediaType, _, _ := mime.ParseMediaType(e.ContentType())
+	formatsByMediaType[mediaType] = name
+}
+
+func init() {
+	RegisterEncoder("json", jsonEncoder{})
+	RegisterEncoder("ndjson", ndjsonEncoder{})
+	RegisterEncoder("csv", csvEncoder{})
+	RegisterEncoder("text", textEncoder{})
+}
+
+// negotiate picks the encoder for a request: `format=` wins, then the first
+// supported media type in Accept by preference, then JSON.
+func negotiate(r *http.Request) (Encoder, string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		e, ok := encoders[format]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(formatNames(), ", "))
+		}
+		return e, format, nil
+	}
+
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+
+	ranges := []accepted{}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = v
+		}
+
+		ranges = append(ranges, accepted{mediaType, q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, rg := range ranges {
+		if rg.q <= 0 {
+			continue
+		}
+		if format, ok := formatsByMediaType[rg.mediaType]; ok {
+			return encoders[format], format, nil
+		}
+	}
+
+	return encoders[defaultFormat], defaultFormat, nil
+}
+
+func formatNames() []string {
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JSON
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) Encode(w io.Writer, _ url.Values, content any) error {
+	buf, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// NDJSON, one card per line, flushed as it goes
+
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) ContentType() string { return "application/x-ndjson" }
+
+func (ndjsonEncoder) Encode(w io.Writer, _ url.Values, content any) error {
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	for _, item := range content.(cardContent).cardItems() {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	return nil
+}
+
+// CSV, one row per card or, with `rows=face`, per side
+
+type csvEncoder struct{}
+
+// csvColumns must only ever be appended to, spreadsheets rely on the order.
+var csvColumns = []string{
+	"id", "name", "face", "set", "creator", "mv", "cost", "colors", "type_line",
+	"oracle_text", "power", "toughness", "loyalty", "keywords", "tags", "legality",
+}
+
+func (csvEncoder) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (csvEncoder) Encode(w io.Writer, params url.Values, content any) error {
+	perFace := false
+
+	switch params.Get("rows") {
+	case "", "card":
+	case "face":
+		perFace = true
+	default:
+		return &APIError{Message: "Invalid rows, expected \"card\" or \"face\""}
+	}
+
+	out := csv.NewWriter(w)
+
+	if err := out.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, item := range content.(cardContent).cardItems() {
+		c := &item.Card
+
+		if !perFace || len(c.Sides) == 0 {
+			if err := out.Write(csvRow(c, "", c.Sides)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i := range c.Sides {
+			if err := out.Write(csvRow(c, strconv.Itoa(i), c.Sides[i:i+1])); err != nil {
+				return err
+			}
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// csvRow renders a card, or one of its sides, in csvColumns order. Side
+// values of several sides are joined like type lines.
+func csvRow(c *cards.Card, face string, sides []cards.Side) []string {
+	mv := formatNumber(c.ManaValue)
+	typeLine := c.TypeLine
+
+	if face != "" {
+		mv = strconv.FormatFloat(sides[0].ManaValue, 'f', -1, 64)
+		typeLine = sides[0].TypeLine
+	}
+
+	return []string{
+		c.ID,
+		c.Name,
+		face,
+		c.Set,
+		c.Creator,
+		mv,
+		joinSides(sides, func(s *cards.Side) string { return s.Cost }),
+		strings.Join(c.Colors, ";"),
+		typeLine,
+		joinSides(sides, func(s *cards.Side) string { return s.TextBox }),
+		joinSides(sides, func(s *cards.Side) string { return originalOr(s.PowerOriginal, s.Power) }),
+		joinSides(sides, func(s *cards.Side) string { return originalOr(s.ToughnessOriginal, s.Toughness) }),
+		joinSides(sides, func(s *cards.Side) string { return originalOr(s.LoyaltyOriginal, s.Loyalty) }),
+		strings.Join(c.Keywords, ";"),
+		strings.Join(c.Tags, ";"),
+		strings.Join(c.Legality, ";"),
+	}
+}
+
+func joinSides(sides []cards.Side, value func(s *cards.Side) string) string {
+	values := make([]string, len(sides))
+	empty := true
+
+	for i := range sides {
+		values[i] = value(&sides[i])
+		if values[i] != "" {
+			empty = false
+		}
+	}
+
+	if empty {
+		return ""
+	}
+
+	return strings.Join(values, cards.FaceSeparator)
+}
+
+func originalOr(original *string, number *float64) string {
+	if original != nil {
+		return *original
+	}
+	return formatNumber(number)
+}
+
+func formatNumber(n *float64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*n, 'f', -1, 64)
+}
+
+// Plain text, rendered like an oracle card
+
+type textEncoder struct{}
+
+func (textEncoder) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (textEncoder) Encode(w io.Writer, _ url.Values, content any) error {
+	items := content.(cardContent).cardItems()
+
+	for i, item := range items {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, oracleText(&item.Card)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// oracleText renders a card the way it reads on paper:
+//
+//	Goblin Guide {R}
+//	Creature — Goblin Scout
+//	Haste
+//	2/2
+//
+// Sides are separated by "----" and start with their own name, when the card
+// name lists one per side.
+func oracleText(c *cards.Card) string {
+	var sb strings.Builder
+
+	if len(c.Sides) == 0 {
+		sb.WriteString(c.Name + "\n")
+	}
+
+	names := sideNames(c)
+
+	for i, s := range c.Sides {
+		if i > 0 {
+			sb.WriteString("----\n")
+		}
+
+		sb.WriteString(strings.TrimSpace(names[i]+" "+s.Cost) + "\n")
+
+		for _, line := range []string{s.TypeLine, s.TextBox} {
+			if line != "" {
+				sb.WriteString(line + "\n")
+			}
+		}
+
+		power := originalOr(s.PowerOriginal, s.Power)
+		toughness := originalOr(s.ToughnessOriginal, s.Toughness)
+
+		switch {
+		case power != "" && toughness != "":
+			sb.WriteString(power + "/" + toughness + "\n")
+		case power != "":
+			sb.WriteString("Power: " + power + "\n")
+		case toughness != "":
+			sb.WriteString("Toughness: " + toughness + "\n")
+		}
+
+		if loyalty := originalOr(s.LoyaltyOriginal, s.Loyalty); loyalty != "" {
+			sb.WriteString("Loyalty: " + loyalty + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// sideNames splits "Beast Rider // Giant Wagon" into a name per side. Cards
+// whose name doesn't list every side use it for all of them.
+func sideNames(c *cards.Card) []string {
+	names := strings.Split(c.Name, cards.FaceSeparator)
+	if len(names) == len(c.Sides) {
+		return names
+	}
+
+	names = make([]string, len(c.Sides))
+	for i := range names {
+		names[i] = c.Name
+	}
+	return names
+}
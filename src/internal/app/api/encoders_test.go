@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"hf-api/src/internal/data"
+	"hf-api/src/pkg/cards"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func number(v float64) *float64 {
+	return &v
+}
+
+func encoderHandler() http.Handler {
+	return NewRouterHandler(data.NewMemoryStore(cards.Database{Cards: []cards.Card{
+		{
+			ID: "1", Name: "Goblin Guide", ManaValue: number(1), TypeLine: "Creature — Goblin Scout",
+			Sides: []cards.Side{
+				{Cost: "{R}", TypeLine: "Creature — Goblin Scout", ManaValue: 1, Power: number(2), Toughness: number(2), TextBox: "Haste"},
+			},
+		},
+		{
+			ID: "2", Name: "Beast Rider // Giant Wagon", ManaValue: number(2), TypeLine: "Creature — Beast // Artifact — Vehicle",
+			Sides: []cards.Side{
+				{Cost: "{1}{G}", TypeLine: "Creature — Beast", ManaValue: 2, Power: number(2), Toughness: number(2), TextBox: "Draw a card."},
+				{TypeLine: "Artifact — Vehicle", Power: number(6), Toughness: number(6), TextBox: "Trample"},
+			},
+		},
+	}}), Config{})
+}
+
+func readCSV(t *testing.T, body string) [][]string {
+	t.Helper()
+
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v\n%s", err, body)
+	}
+	return records
+}
+
+// The README promises spreadsheets that columns are only added at the end
+func TestCSVColumns(t *testing.T) {
+	rec := serve(t, encoderHandler(), "/v1/cards/search?q=goblin&format=csv", nil)
+
+	header := strings.SplitN(rec.Body.String(), "\n", 2)[0]
+	want := "id,name,face,set,creator,mv,cost,colors,type_line,oracle_text,power,toughness,loyalty,keywords,tags,legality"
+
+	if !strings.HasPrefix(header, want) {
+		t.Errorf("CSV header is %s, want it to start with %s", header, want)
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	handler := encoderHandler()
+
+	byCard := readCSV(t, serve(t, handler, "/v1/cards/search?q=rider&format=csv", nil).Body.String())
+	byFace := readCSV(t, serve(t, handler, "/v1/cards/search?q=rider&format=csv&rows=face", nil).Body.String())
+
+	column := func(name string) int { return slices.Index(csvColumns, name) }
+
+	if len(byCard) != 2 {
+		t.Fatalf("rows=card gave %d rows, want a header and a card", len(byCard))
+	}
+
+	card := byCard[1]
+	if card[column("face")] != "" || card[column("power")] != "2 // 6" || card[column("cost")] != "{1}{G} // " {
+		t.Errorf("rows=card gave %q", card)
+	}
+
+	if len(byFace) != 3 {
+		t.Fatalf("rows=face gave %d rows, want a header and a row per side", len(byFace))
+	}
+
+	for i, want := range []struct{ face, typeLine, power, mv string }{
+		{"0", "Creature — Beast", "2", "2"},
+		{"1", "Artifact — Vehicle", "6", "0"},
+	} {
+		row := byFace[i+1]
+		if row[column("id")] != "2" || row[column("face")] != want.face || row[column("type_line")] != want.typeLine ||
+			row[column("power")] != want.power || row[column("mv")] != want.mv {
+			t.Errorf("rows=face row %d is %q", i, row)
+		}
+	}
+
+	if rec := serve(t, handler, "/v1/cards/search?q=rider&format=csv&rows=side", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("rows=side returned %d, want 400", rec.Code)
+	}
+}
+
+func TestNDJSONLines(t *testing.T) {
+	rec := serve(t, encoderHandler(), "/v1/cards/search?q=mv>=0&format=ndjson", nil)
+
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type is %q", got)
+	}
+
+	body := rec.Body.String()
+	if !strings.HasSuffix(body, "}\n") {
+		t.Errorf("the last card isn't ended by a newline: %q", body)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per card: %q", len(lines), body)
+	}
+
+	for _, line := range lines {
+		var card CardResult
+		if err := json.Unmarshal([]byte(line), &card); err != nil || card.ID == "" {
+			t.Errorf("line %q isn't a card: %v", line, err)
+		}
+	}
+}
+
+func TestFormatNegotiation(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		accept      string
+		code        int
+		contentType string
+	}{
+		{"default", "", "", http.StatusOK, "application/json"},
+		{"accept", "", "text/csv", http.StatusOK, "text/csv; charset=utf-8"},
+		{"format wins over accept", "&format=text", "text/csv", http.StatusOK, "text/plain; charset=utf-8"},
+		{"format json wins over accept", "&format=json", "application/x-ndjson", http.StatusOK, "application/json"},
+		{"accept by preference", "", "text/csv;q=0.5, application/x-ndjson", http.StatusOK, "application/x-ndjson"},
+		{"accept skips q=0", "", "text/csv;q=0, text/plain;q=0.1", http.StatusOK, "text/plain; charset=utf-8"},
+		{"unknown accept", "", "image/png", http.StatusOK, "application/json"},
+		{"unknown format", "&format=xml", "text/csv", http.StatusBadRequest, "application/json"},
+	}
+
+	handler := encoderHandler()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.accept != "" {
+				header.Set("Accept", tt.accept)
+			}
+
+			rec := serve(t, handler, "/v1/cards/search?q=goblin"+tt.query, header)
+
+			if rec.Code != tt.code || rec.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("returned %d %q, want %d %q", rec.Code, rec.Header().Get("Content-Type"), tt.code, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestOracleText(t *testing.T) {
+	star := "*"
+
+	tests := []struct {
+		name string
+		card cards.Card
+		want string
+	}{
+		{
+			"creature",
+			cards.Card{Name: "Goblin Guide", Sides: []cards.Side{
+				{Cost: "{R}", TypeLine: "Creature — Goblin Scout", TextBox: "Haste", Power: number(2), Toughness: number(2)},
+			}},
+			"Goblin Guide {R}\nCreature — Goblin Scout\nHaste\n2/2\n",
+		},
+		{
+			"power without toughness",
+			cards.Card{Name: "Odd Thing", Sides: []cards.Side{
+				{TypeLine: "Artifact", Power: number(2)},
+			}},
+			"Odd Thing\nArtifact\nPower: 2\n",
+		},
+		{
+			"toughness without power",
+			cards.Card{Name: "Wall of Odds", Sides: []cards.Side{
+				{TypeLine: "Creature — Wall", ToughnessOriginal: &star},
+			}},
+			"Wall of Odds\nCreature — Wall\nToughness: *\n",
+		},
+		{
+			"planeswalker",
+			cards.Card{Name: "Planeswalker Pal", Sides: []cards.Side{
+				{Cost: "{2}{W}{W}", TypeLine: "Legendary Planeswalker — Pal", Loyalty: number(4)},
+			}},
+			"Planeswalker Pal {2}{W}{W}\nLegendary Planeswalker — Pal\nLoyalty: 4\n",
+		},
+		{
+			"named sides",
+			cards.Card{Name: "Beast Rider // Giant Wagon", Sides: []cards.Side{
+				{Cost: "{1}{G}", TypeLine: "Creature — Beast", Power: number(2), Toughness: number(2)},
+				{TypeLine: "Artifact — Vehicle", TextBox: "Trample"},
+			}},
+			"Beast Rider {1}{G}\nCreature — Beast\n2/2\n----\nGiant Wagon\nArtifact — Vehicle\nTrample\n",
+		},
+		{
+			"unnamed sides",
+			cards.Card{Name: "Flip Card", Sides: []cards.Side{
+				{TypeLine: "Instant"},
+				{Cost: "{U}", TypeLine: "Sorcery"},
+			}},
+			"Flip Card\nInstant\n----\nFlip Card {U}\nSorcery\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := oracleText(&tt.card); got != tt.want {
+				t.Errorf("oracleText gave\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
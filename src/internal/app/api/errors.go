@@ -1,7 +1,13 @@
 package api
 
+import "hf-api/src/internal/query"
+
 type APIError struct {
-	Message    string `json:"message"`
+	Message string        `json:"message"`
+	Details []query.Issue `json:"details,omitempty"`
+	// RequestID identifies the request in the logs, filled in when the error
+	// is sent.
+	RequestID  string `json:"request_id,omitempty"`
 	InnerError error  `json:"-"`
 }
 
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"hf-api/src/pkg/cards"
+	"reflect"
+	"strings"
+)
+
+// projection is a set of JSON paths to keep in a card, e.g. `name,sides.cost`
+// becomes {"name": nil, "sides": {"cost": nil}}. A nil projection keeps the
+// whole value.
+type projection map[string]projection
+
+// projectable is implemented by responses made of cards, which can be sent
+// down to the fields asked for with `fields=`.
+type projectable interface {
+	project(fields projection)
+}
+
+func (l *CardList) project(fields projection) {
+	for i := range l.Data {
+		l.Data[i].fields = fields
+	}
+}
+
+func (r *CardResult) project(fields projection) {
+	r.fields = fields
+}
+
+func (l *RelatedList) project(fields projection) {
+	for i := range l.Data {
+		if l.Data[i].Card != nil {
+			l.Data[i].Card.fields = fields
+		}
+	}
+}
+
+// cardSchema lists the JSON paths of a card, nested the same way as a
+// projection. Fields hidden from JSON are left out.
+var cardSchema = schemaOf(reflect.TypeOf(cards.Card{}))
+
+func schemaOf(t reflect.Type) projection {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	schema := projection{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema[name] = schemaOf(field.Type)
+	}
+
+	return schema
+}
+
+// parseFields turns `fields=name,mv,sides.cost` into a projection, checking
+// every path against the card schema.
+func parseFields(value string) (projection, *APIError) {
+	fields := projection{}
+	unknown := []string{}
+
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		if !fields.add(strings.Split(path, "."), cardSchema) {
+			unknown = append(unknown, path)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, &APIError{Message: fmt.Sprintf("Unknown fields: %s", strings.Join(unknown, ", "))}
+	}
+
+	if len(fields) == 0 {
+		return nil, &APIError{Message: "No fields requested"}
+	}
+
+	return fields, nil
+}
+
+func (p projection) add(path []string, schema projection) bool {
+	sub, ok := schema[path[0]]
+	if !ok {
+		return false
+	}
+
+	if len(path) == 1 {
+		p[path[0]] = nil
+		return true
+	}
+
+	if sub == nil {
+		return false
+	}
+
+	existing, seen := p[path[0]]
+	if seen && existing == nil {
+		// The whole value is already kept, but the path must still be valid
+		return projection{}.add(path[1:], sub)
+	}
+
+	if existing == nil {
+		existing = projection{}
+	}
+
+	if !existing.add(path[1:], sub) {
+		return false
+	}
+
+	p[path[0]] = existing
+	return true
+}
+
+// apply keeps the projected fields of a decoded JSON value.
+func (p projection) apply(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(p))
+		for name, sub := range p {
+			value, ok := v[name]
+			if !ok {
+				continue
+			}
+			if sub == nil {
+				out[name] = value
+			} else {
+				out[name] = sub.apply(value)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = p.apply(item)
+		}
+		return out
+	}
+
+	return v
+}
+
+// cardResultJSON has the fields of CardResult without its MarshalJSON.
+type cardResultJSON CardResult
+
+// MarshalJSON sends the whole card, or only the projected fields when
+// `fields=` was given. matched_face is always kept.
+func (r CardResult) MarshalJSON() ([]byte, error) {
+	full, err := json.Marshal(cardResultJSON(r))
+	if err != nil || r.fields == nil {
+		return full, err
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(full, &decoded); err != nil {
+		return nil, err
+	}
+
+	projected := r.fields.apply(decoded).(map[string]any)
+	if face, ok := decoded["matched_face"]; ok {
+		projected["matched_face"] = face
+	}
+
+	return json.Marshal(projected)
+}
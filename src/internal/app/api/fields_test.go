@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"hf-api/src/internal/data"
+	"hf-api/src/pkg/cards"
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func fieldsHandler() http.Handler {
+	return NewRouterHandler(data.NewMemoryStore(cards.Database{Cards: []cards.Card{{
+		ID: "2", Name: "Beast Rider // Giant Wagon", Set: "HC2", ManaValue: number(2),
+		TypeLine:  "Creature — Beast // Artifact — Vehicle",
+		ImageURIs: &cards.ImageURIs{Normal: "https://example.com/2.png", Small: "https://example.com/2-small.png"},
+		Sides: []cards.Side{
+			{Cost: "{1}{G}", TypeLine: "Creature — Beast", ManaValue: 2, Power: number(2), Toughness: number(2), TextBox: "Draw a card."},
+			{TypeLine: "Artifact — Vehicle", Power: number(6), Toughness: number(6), TextBox: "Trample"},
+		},
+	}}}), Config{})
+}
+
+// searchFields returns the cards found by a search, decoded as JSON.
+func searchFields(t *testing.T, target string) []map[string]any {
+	t.Helper()
+
+	rec := serve(t, fieldsHandler(), target, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s returned %d: %s", target, rec.Code, rec.Body)
+	}
+
+	var list struct {
+		Data []map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) == 0 {
+		t.Fatalf("%s found no cards", target)
+	}
+
+	return list.Data
+}
+
+func keys(m map[string]any) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+func TestFieldsSides(t *testing.T) {
+	card := searchFields(t, "/v1/cards/search?q=rider&fields=id,name,sides.cost")[0]
+
+	if got := keys(card); !slices.Equal(got, []string{"id", "name", "sides"}) {
+		t.Fatalf("card has %v", got)
+	}
+
+	sides := card["sides"].([]any)
+	if len(sides) != 2 {
+		t.Fatalf("card has %d sides, want 2", len(sides))
+	}
+
+	for i, side := range sides {
+		if got := keys(side.(map[string]any)); !slices.Equal(got, []string{"cost"}) {
+			t.Errorf("side %d has %v, want only cost", i, got)
+		}
+	}
+
+	if cost := sides[0].(map[string]any)["cost"]; cost != "{1}{G}" {
+		t.Errorf("side 0 costs %v", cost)
+	}
+}
+
+func TestFieldsImageURIs(t *testing.T) {
+	card := searchFields(t, "/v1/cards/search?q=rider&fields=image_uris.normal")[0]
+
+	got, _ := json.Marshal(card)
+	if want := `{"image_uris":{"normal":"https://example.com/2.png"}}`; string(got) != want {
+		t.Errorf("card is %s, want %s", got, want)
+	}
+}
+
+func TestFieldsKeepMatchedFace(t *testing.T) {
+	card := searchFields(t, "/v1/cards/search?q=side:(t:vehicle)&fields=name")[0]
+
+	if got := keys(card); !slices.Equal(got, []string{"matched_face", "name"}) {
+		t.Fatalf("card has %v, want name and matched_face", got)
+	}
+
+	if face := card["matched_face"]; face != 1.0 {
+		t.Errorf("matched_face is %v, want 1", face)
+	}
+
+	// A card matched as a whole has no face to keep
+	card = searchFields(t, "/v1/cards/search?q=rider&fields=name")[0]
+
+	if got := keys(card); !slices.Equal(got, []string{"name"}) {
+		t.Errorf("card has %v, want only name", got)
+	}
+}
@@ -4,15 +4,15 @@ import (
 	"context"
 	"fmt"
 	"hf-api/src/internal/data"
+	"hf-api/src/internal/query"
 	"hf-api/src/pkg/cards"
-	"math"
 	"net/http"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/blevesearch/bleve/v2"
-	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/blevesearch/bleve/v2/search"
 )
 
 type HealthResponse struct {
@@ -32,87 +32,226 @@ func health(ctx context.Context, req *http.Request) *APIResponse {
 
 // /cards/search
 
-const epsilon = 10e-3 // We don't need super high precision for this
-
-type Filter struct {
-	Key      string
-	Operator string
-	Value    string
+const pageSize = 10
+
+// maxPage keeps (page-1)*pageSize far from overflowing. It is well past the
+// last page of any search.
+const maxPage = 100_000
+
+// CardList is a page of search results, shaped after Scryfall's list object.
+// Query is the canonical form of the search and Description explains it in
+// plain English. Warnings describe the parts of the query that were ignored.
+type CardList struct {
+	Object      string        `json:"object"`
+	TotalCards  int           `json:"total_cards"`
+	HasMore     bool          `json:"has_more"`
+	Query       string        `json:"query"`
+	Description string        `json:"description"`
+	Data        []CardResult  `json:"data"`
+	Warnings    []query.Issue `json:"warnings,omitempty"`
+	Debug       *SearchDebug  `json:"debug,omitempty"`
 }
 
-var KnownTokens = map[string][]string{
-	"name":        {"name", "n"},
-	"colors":      {"colors", "c", "color"},
-	"mv":          {"mv", "cmc"},
-	"mana":        {"mana", "m"},
-	"identity":    {"identity", "id"},
-	"type_line":   {"type_line", "type", "t"},
-	"oracle":      {"oracle", "o"},
-	"flavor_text": {"flavor_text", "ft", "flavor", "flavortext"},
-
-	"power":           {"power", "pow"},
-	"toughness":       {"toughness", "tou", "tough"},
-	"power_toughness": {"power_toughness", "pt", "powtou"},
-	"loyalty":         {"loyalty", "loy"},
+// CardResult is a card returned by a search. MatchedFace is the index of the
+// side that satisfied a side-aware query, and is omitted otherwise.
+type CardResult struct {
+	cards.Card
+	MatchedFace *int `json:"matched_face,omitempty"`
 
-	"devotion": {"devotion"},
-	"produces": {"produces"},
+	fields projection // set by `fields=`, see MarshalJSON
+}
 
-	"set":     {"set", "s", "edition", "e"},
-	"tags":    {"tags", "tag"},
-	"creator": {"creator", "author"},
+// SearchDebug is returned with `debug=true`. AST is the query after aliases
+// are resolved, BleveQuery what the store sent to its index (null when it has
+// none), and Timings how long each stage took.
+type SearchDebug struct {
+	AST        query.Node        `json:"ast"`
+	BleveQuery any               `json:"bleve_query"`
+	Hits       []HitDebug        `json:"hits"`
+	Timings    map[string]string `json:"timings"`
+}
 
-	"format": {"format", "f"},
-	"banned": {"banned", "ban"},
+type HitDebug struct {
+	Name        string              `json:"name"`
+	Score       float64             `json:"score"`
+	Explanation *search.Explanation `json:"explanation,omitempty"`
 }
 
-func search(ctx context.Context, req *http.Request) *APIResponse {
-	query := req.URL.Query().Get("q")
+func (rt *router) search(ctx context.Context, req *http.Request) *APIResponse {
+	params := req.URL.Query()
+	q := params.Get("q")
 
-	if query == "" {
+	if q == "" {
 		return &APIResponse{
 			Code:  http.StatusBadRequest,
 			Error: &APIError{Message: "Empty search"},
 		}
 	}
 
-	filters := extractFitersFromQuery(query)
+	debug := params.Get("debug") == "true"
 
-	// Fallback: if no filters were extracted, treat entire query as name search
-	if len(filters) == 0 {
-		filters = []Filter{
-			{Key: "name", Operator: ":", Value: query},
+	if debug && !rt.config.AllowDebug {
+		return &APIResponse{
+			Code:  http.StatusForbidden,
+			Error: &APIError{Message: "Debug mode is disabled"},
 		}
 	}
 
-	// searchQuery := bleve.NewSearchRequest(buildBleveQuery(filters))
-	searchQuery := bleve.NewQueryStringQuery(query)
+	timings := map[string]string{}
+	start := time.Now()
+	lap := start
+
+	root, warnings, err := query.Parse(q)
+
+	if syntaxErr, ok := err.(*query.SyntaxError); ok {
+		return &APIResponse{
+			Code: http.StatusBadRequest,
+			Error: &APIError{
+				Message:    "Invalid query syntax",
+				Details:    []query.Issue{syntaxErr.Issue},
+				InnerError: err,
+			},
+		}
+	}
 
-	if _, err := searchQuery.Parse(); err != nil {
+	if err != nil {
 		return &APIResponse{
 			Code:  http.StatusBadRequest,
-			Error: &APIError{Message: "Invalid query syntax"},
+			Error: wrapError("Invalid query syntax", err),
+		}
+	}
+
+	if root == nil && len(warnings) > 0 {
+		return &APIResponse{
+			Code: http.StatusBadRequest,
+			Error: &APIError{
+				Message: "All of your terms were ignored",
+				Details: warnings,
+			},
 		}
 	}
 
-	searchRequest := bleve.NewSearchRequest(searchQuery)
-	searchRequest.Fields = []string{"*"}
-	searchRequest.Size = 10
+	// Fallback: if nothing could be understood, treat entire query as name search
+	if root == nil {
+		root = &query.Filter{Key: "name", Operator: ":", Value: q}
+	}
+
+	switch params.Get("face") {
+	case "", "any":
+	case "same":
+		root = query.SameFace(root)
+	default:
+		return &APIResponse{
+			Code:  http.StatusBadRequest,
+			Error: &APIError{Message: "Invalid face mode, expected \"any\" or \"same\""},
+		}
+	}
+
+	// Tokens are left out unless asked for, either explicitly or by a query
+	// about them (`is:token`, `-is:token`)
+	includeTokens := query.Mentions(root, "is", "token")
+
+	switch params.Get("include") {
+	case "":
+	case "tokens":
+		includeTokens = true
+	default:
+		return &APIResponse{
+			Code:  http.StatusBadRequest,
+			Error: &APIError{Message: "Invalid include, expected \"tokens\""},
+		}
+	}
+
+	page := 1
+
+	if v := params.Get("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 || page > maxPage {
+			return &APIResponse{
+				Code:  http.StatusBadRequest,
+				Error: &APIError{Message: fmt.Sprintf("Invalid page, expected a number between 1 and %d", maxPage)},
+			}
+		}
+	}
+
+	order := params.Get("order")
+
+	if order == "" {
+		order = data.OrderRelevance
+	} else if !slices.Contains(data.Orders, order) {
+		return &APIResponse{
+			Code:  http.StatusBadRequest,
+			Error: &APIError{Message: fmt.Sprintf("Invalid order, expected one of %s", strings.Join(data.Orders, ", "))},
+		}
+	}
+
+	searched := root
+	if !includeTokens {
+		searched = &query.And{Nodes: []query.Node{root, &query.Not{Node: query.IsToken}}}
+	}
 
-	results, err := data.Index.SearchInContext(ctx, searchRequest)
+	timings["parse"] = time.Since(lap).String()
+	lap = time.Now()
+
+	results, err := rt.storeFor(ctx).Search(ctx, data.SearchRequest{
+		Query:   searched,
+		Order:   order,
+		Offset:  (page - 1) * pageSize,
+		Size:    pageSize,
+		Explain: debug,
+	})
 
 	if err != nil {
 		return &APIResponse{
 			Code:  http.StatusInternalServerError,
-			Error: &APIError{Message: "Search failed"},
+			Error: wrapError("Search failed", err),
 		}
 	}
 
-	content := make([]cards.Card, len(results.Hits))
+	timings["search"] = time.Since(lap).String()
+
+	content := CardList{
+		Object:      "list",
+		TotalCards:  results.Total,
+		HasMore:     results.Total > (page-1)*pageSize+len(results.Hits),
+		Query:       query.String(root),
+		Description: query.Describe(root),
+		Data:        []CardResult{},
+		Warnings:    warnings,
+	}
+
+	for _, hit := range results.Hits {
+		result := CardResult{Card: rt.withImages(hit.Card)}
+
+		if hit.Face >= 0 {
+			face := hit.Face
+			result.MatchedFace = &face
+		}
 
-	for i, hit := range results.Hits {
-		id, _ := strconv.Atoi(hit.ID)
-		content[i] = data.DB[id]
+		content.Data = append(content.Data, result)
+	}
+
+	timings["total"] = time.Since(start).String()
+
+	if debug {
+		for stage, elapsed := range results.Timings {
+			timings["store_"+stage] = elapsed.String()
+		}
+
+		content.Debug = &SearchDebug{
+			AST:        searched,
+			BleveQuery: results.Plan,
+			Hits:       []HitDebug{},
+			Timings:    timings,
+		}
+
+		for _, hit := range results.Hits {
+			content.Debug.Hits = append(content.Debug.Hits, HitDebug{
+				Name:        hit.Card.Name,
+				Score:       hit.Score,
+				Explanation: hit.Explanation,
+			})
+		}
 	}
 
 	return &APIResponse{
@@ -121,85 +260,267 @@ func search(ctx context.Context, req *http.Request) *APIResponse {
 	}
 }
 
-func buildBleveQuery(filters []Filter) *query.BooleanQuery {
-	query := bleve.NewBooleanQuery()
+// /cards/{id}/related
 
-	for _, filter := range filters {
-		if filter.Operator == ":" {
-			matchQuery := bleve.NewMatchQuery(filter.Value)
-			matchQuery.SetField(filter.Key)
-			query.AddMust(matchQuery)
-			continue
+// RelatedList holds the tokens and components related to a card. Card is set
+// when the part is a card of its own, and omitted for tokens that only exist
+// on the cards creating them.
+type RelatedList struct {
+	Object string          `json:"object"`
+	Data   []RelatedResult `json:"data"`
+}
+
+type RelatedResult struct {
+	cards.RelatedCard
+	Card *CardResult `json:"card,omitempty"`
+}
+
+func (rt *router) related(ctx context.Context, req *http.Request) *APIResponse {
+	store := rt.storeFor(ctx)
+	card, ok := store.Get(req.PathValue("id"))
+
+	if !ok {
+		return &APIResponse{
+			Code:  http.StatusNotFound,
+			Error: &APIError{Message: "Card not found"},
 		}
+	}
 
-		var num, min, max float64
-		var err error
+	content := RelatedList{
+		Object: "list",
+		Data:   []RelatedResult{},
+	}
 
-		if num, err = strconv.ParseFloat(filter.Value, 64); err != nil {
+	for _, part := range card.AllParts {
+		if part.ID == card.ID {
 			continue
 		}
 
-		switch filter.Operator {
-		case "=":
-			min = num - epsilon
-			max = num + epsilon
-		case ">":
-			min = num + epsilon
-			max = math.Inf(1)
-		case "<":
-			min = math.Inf(-1)
-			max = num - epsilon
-		case ">=":
-			min = num - epsilon
-			max = math.Inf(1)
-		case "<=":
-			min = math.Inf(-1)
-			max = num + epsilon
+		result := RelatedResult{RelatedCard: part}
+		if c, ok := store.Get(part.ID); ok {
+			result.Card = &CardResult{Card: rt.withImages(c)}
 		}
 
-		rangeQuery := bleve.NewNumericRangeQuery(&min, &max)
-		rangeQuery.SetField(filter.Key)
-		query.AddMust(rangeQuery)
+		content.Data = append(content.Data, result)
 	}
 
-	return query
+	return &APIResponse{
+		Code:    http.StatusOK,
+		Content: &content,
+	}
 }
 
-func extractFitersFromQuery(query string) []Filter {
-	// Extract tokens from query https://scryfall.com/docs/syntax
-	re := regexp.MustCompile(`(\w+)([:=><]=?)(?:"([^"]+)"|([^"\s]+))(?:\s|$)`)
-	matches := re.FindAllStringSubmatch(query, -1)
+// /tokens
+
+// TokenList is the token catalogue, optionally narrowed down to the tokens
+// created by a set of cards.
+type TokenList struct {
+	Object      string               `json:"object"`
+	TotalTokens int                  `json:"total_tokens"`
+	Data        []cards.CatalogToken `json:"data"`
+}
 
-	filters := []Filter{}
-	for _, match := range matches {
-		if len(match) < 4 {
-			fmt.Println("malformed filter:", match[0])
-			// malformed filter; skip
+func (rt *router) tokens(ctx context.Context, req *http.Request) *APIResponse {
+	store := rt.storeFor(ctx)
+	content := TokenList{
+		Object: "list",
+		Data:   []cards.CatalogToken{},
+	}
+
+	// `created_by=<id>,<id>,...` lists the tokens a deck or cube needs
+	var creators map[string]struct{}
+
+	if ids := req.URL.Query().Get("created_by"); ids != "" {
+		creators = map[string]struct{}{}
+
+		for _, id := range strings.Split(ids, ",") {
+			id = strings.TrimSpace(id)
+			if _, ok := store.Get(id); !ok {
+				return &APIResponse{
+					Code:  http.StatusBadRequest,
+					Error: &APIError{Message: fmt.Sprintf("Unknown card ID %q", id)},
+				}
+			}
+			creators[id] = struct{}{}
+		}
+	}
+
+	for _, token := range store.Tokens() {
+		if creators != nil && !createdByAny(token, creators) {
 			continue
 		}
+		token.Image = rt.rewriteImageURL(token.Image)
+		content.Data = append(content.Data, token)
+	}
+
+	content.TotalTokens = len(content.Data)
+
+	return &APIResponse{
+		Code:    http.StatusOK,
+		Content: &content,
+	}
+}
+
+func createdByAny(token cards.CatalogToken, creators map[string]struct{}) bool {
+	for _, part := range token.CreatedBy {
+		if _, ok := creators[part.ID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// /cards/{id}
+
+func (rt *router) card(ctx context.Context, req *http.Request) *APIResponse {
+	card, ok := rt.storeFor(ctx).Get(req.PathValue("id"))
+
+	if !ok {
+		return &APIResponse{
+			Code:  http.StatusNotFound,
+			Error: &APIError{Message: "Card not found"},
+		}
+	}
+
+	return rt.cardResponse(req, card)
+}
+
+// /cards/named
 
-		keyAlias := strings.TrimSpace(match[1])
-		key, ok := TokenAliasMap[keyAlias]
+// named finds a card by name, like Scryfall: `exact` must match the whole
+// name (ignoring case), `fuzzy` picks the best match for the given words.
+func (rt *router) named(ctx context.Context, req *http.Request) *APIResponse {
+	params := req.URL.Query()
 
+	if exact := params.Get("exact"); exact != "" {
+		card, ok := rt.storeFor(ctx).Named(exact)
 		if !ok {
-			fmt.Println("unknown filter key:", keyAlias)
-			// unknown filter key; skip
-			continue
+			return &APIResponse{
+				Code:  http.StatusNotFound,
+				Error: &APIError{Message: fmt.Sprintf("No card named %q", exact)},
+			}
 		}
+		return rt.cardResponse(req, card)
+	}
 
-		op := strings.TrimSpace(match[2])
-		value := strings.TrimSpace(match[3])
+	fuzzy := params.Get("fuzzy")
 
-		if value == "" {
-			value = strings.TrimSpace(match[4])
+	if fuzzy == "" {
+		return &APIResponse{
+			Code:  http.StatusBadRequest,
+			Error: &APIError{Message: "Either exact or fuzzy is required"},
 		}
+	}
+
+	if card, ok := rt.storeFor(ctx).Named(fuzzy); ok {
+		return rt.cardResponse(req, card)
+	}
+
+	results, err := rt.storeFor(ctx).Search(ctx, data.SearchRequest{
+		Query: &query.Filter{Key: "name", Operator: ":", Value: fuzzy},
+		Order: data.OrderRelevance,
+		Size:  1,
+	})
 
-		filters = append(filters, Filter{
-			Key:      key,
-			Operator: op,
-			Value:    value,
-		})
+	if err != nil {
+		return &APIResponse{
+			Code:  http.StatusInternalServerError,
+			Error: wrapError("Search failed", err),
+		}
 	}
 
-	return filters
+	if len(results.Hits) == 0 {
+		return &APIResponse{
+			Code:  http.StatusNotFound,
+			Error: &APIError{Message: fmt.Sprintf("No card found matching %q", fuzzy)},
+		}
+	}
+
+	return rt.cardResponse(req, results.Hits[0].Card)
+}
+
+// cardResponse sends a single card, or with `format=image` redirects to its
+// image. `face` picks the image of a side instead of the card's.
+func (rt *router) cardResponse(req *http.Request, card *cards.Card) *APIResponse {
+	params := req.URL.Query()
+
+	if params.Get("format") == "image" {
+		return rt.imageRedirect(card, params.Get("face"))
+	}
+
+	content := CardResult{Card: rt.withImages(card)}
+
+	return &APIResponse{
+		Code:    http.StatusOK,
+		Content: &content,
+	}
+}
+
+func (rt *router) imageRedirect(card *cards.Card, face string) *APIResponse {
+	uris := card.ImageURIs
+
+	if face != "" {
+		i, err := strconv.Atoi(face)
+
+		if err != nil || i < 0 || i >= len(card.Sides) {
+			return &APIResponse{
+				Code:  http.StatusBadRequest,
+				Error: &APIError{Message: fmt.Sprintf("Invalid face, expected a side index between 0 and %d", len(card.Sides)-1)},
+			}
+		}
+
+		uris = card.Sides[i].ImageURIs
+	}
+
+	if uris == nil {
+		return &APIResponse{
+			Code:  http.StatusNotFound,
+			Error: &APIError{Message: "Card has no image"},
+		}
+	}
+
+	return &APIResponse{
+		Code:   http.StatusFound,
+		Header: http.Header{"Location": {rt.rewriteImageURL(uris.Normal)}},
+	}
+}
+
+// /cards/{id}/rulings
+
+// RulingList holds the rulings of a card, shaped after Scryfall's list of
+// ruling objects.
+type RulingList struct {
+	Object  string   `json:"object"`
+	HasMore bool     `json:"has_more"`
+	Data    []Ruling `json:"data"`
+}
+
+type Ruling struct {
+	Object  string `json:"object"`
+	Comment string `json:"comment"`
+}
+
+func (rt *router) rulings(ctx context.Context, req *http.Request) *APIResponse {
+	card, ok := rt.storeFor(ctx).Get(req.PathValue("id"))
+
+	if !ok {
+		return &APIResponse{
+			Code:  http.StatusNotFound,
+			Error: &APIError{Message: "Card not found"},
+		}
+	}
+
+	content := RulingList{
+		Object: "list",
+		Data:   []Ruling{},
+	}
+
+	for _, comment := range card.RulingEntries {
+		content.Data = append(content.Data, Ruling{Object: "ruling", Comment: comment})
+	}
+
+	return &APIResponse{
+		Code:    http.StatusOK,
+		Content: &content,
+	}
 }
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"hf-api/src/internal/data"
+	"hf-api/src/pkg/cards"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func testHandlerStore() data.CardStore {
+	return data.NewMemoryStore(cards.Database{Cards: []cards.Card{
+		{ID: "1", Name: "Goblin Guide"},
+		{ID: "2", Name: "Goblin Bushwhacker"},
+	}})
+}
+
+func testHandler() http.Handler {
+	return NewRouterHandler(testHandlerStore(), Config{})
+}
+
+func TestSearchPage(t *testing.T) {
+	tests := []struct {
+		page string
+		code int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusOK},
+		{"0", http.StatusBadRequest},
+		{"-1", http.StatusBadRequest},
+		{"100001", http.StatusBadRequest},
+		// (page-1)*pageSize overflows to a negative offset
+		{"1844674407370955162", http.StatusBadRequest},
+	}
+
+	handler := testHandler()
+
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/cards/search?q=goblin&page="+tt.page, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Errorf("page=%s returned %d, want %d: %s", tt.page, rec.Code, tt.code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestSearchMatchedFace(t *testing.T) {
+	power := func(v float64) *float64 { return &v }
+
+	store := data.NewMemoryStore(cards.Database{Cards: []cards.Card{{
+		ID:       "1",
+		Name:     "Beast Rider // Giant Wagon",
+		TypeLine: "Creature — Beast // Artifact — Vehicle",
+		Sides: []cards.Side{
+			{TypeLine: "Creature — Beast", Power: power(2)},
+			{TypeLine: "Artifact — Vehicle", Power: power(6)},
+		},
+	}}})
+	handler := NewRouterHandler(store, Config{})
+
+	// A face of -1 means matched_face is left out
+	tests := []struct {
+		query string
+		total int
+		face  int
+	}{
+		{"q=t:creature+pow>5", 1, -1},
+		{"q=t:creature+pow>5&face=same", 0, -1},
+		{"q=t:vehicle+pow>5&face=same", 1, 1},
+		{"q=side:(pow<5)", 1, 0},
+		{"q=-side:(pow>5)", 0, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := serve(t, handler, "/v1/cards/search?"+tt.query, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("returned %d: %s", rec.Code, rec.Body)
+			}
+
+			var list struct {
+				TotalCards int `json:"total_cards"`
+				Data       []struct {
+					MatchedFace *int `json:"matched_face"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+				t.Fatal(err)
+			}
+
+			if list.TotalCards != tt.total {
+				t.Fatalf("found %d cards, want %d", list.TotalCards, tt.total)
+			}
+			if tt.total == 0 {
+				return
+			}
+
+			got := -1
+			if list.Data[0].MatchedFace != nil {
+				got = *list.Data[0].MatchedFace
+			}
+			if got != tt.face {
+				t.Errorf("matched_face = %d, want %d", got, tt.face)
+			}
+		})
+	}
+}
+
+func TestRelatedLeavesOutTheCard(t *testing.T) {
+	maker := cards.Card{ID: "maker", Name: "Goblin Maker", TypeLine: "Sorcery"}
+	goblin := cards.Card{ID: "goblin", Name: "Goblin", TypeLine: "Token Creature — Goblin"}
+
+	maker.AllParts = []cards.RelatedCard{
+		{Object: "related_card", ID: "maker", Component: cards.ComponentComboPiece, Name: "Goblin Maker"},
+		{Object: "related_card", ID: "goblin", Component: cards.ComponentToken, Name: "Goblin"},
+		{Object: "related_card", ID: "treasure", Component: cards.ComponentToken, Name: "Treasure"},
+	}
+
+	handler := NewRouterHandler(data.NewMemoryStore(cards.Database{Cards: []cards.Card{maker, goblin}}), Config{})
+	rec := serve(t, handler, "/v1/cards/maker/related", nil)
+
+	var related RelatedList
+	if err := json.Unmarshal(rec.Body.Bytes(), &related); err != nil {
+		t.Fatalf("returned %d with %s: %v", rec.Code, rec.Body, err)
+	}
+
+	if len(related.Data) != 2 {
+		t.Fatalf("got %d related cards, want the goblin and the treasure: %s", len(related.Data), rec.Body)
+	}
+
+	// Parts that are cards come with the card, the others on their own
+	if got := related.Data[0]; got.ID != "goblin" || got.Card == nil || got.Card.TypeLine != goblin.TypeLine {
+		t.Errorf("first part is %+v", got)
+	}
+	if got := related.Data[1]; got.ID != "treasure" || got.Card != nil {
+		t.Errorf("second part is %+v", got)
+	}
+}
+
+func TestTokensCreatedBy(t *testing.T) {
+	creator := func(id string) cards.RelatedCard {
+		return cards.RelatedCard{Object: "related_card", ID: id, Component: cards.ComponentComboPiece}
+	}
+
+	store := data.NewMemoryStore(cards.Database{
+		Cards: []cards.Card{{ID: "pirate", Name: "Pirate"}, {ID: "maker", Name: "Goblin Maker"}, {ID: "elf", Name: "Elf"}},
+		Tokens: []cards.CatalogToken{
+			{Object: "token", ID: "angel", Name: "Angel", CreatedBy: []cards.RelatedCard{}},
+			{Object: "token", ID: "goblin", Name: "Goblin", CreatedBy: []cards.RelatedCard{creator("maker")}},
+			{Object: "token", ID: "treasure", Name: "Treasure", CreatedBy: []cards.RelatedCard{creator("pirate"), creator("maker")}},
+		},
+	})
+	handler := NewRouterHandler(store, Config{})
+
+	tests := []struct {
+		query string
+		code  int
+		want  []string
+	}{
+		{"", http.StatusOK, []string{"angel", "goblin", "treasure"}},
+		{"?created_by=pirate", http.StatusOK, []string{"treasure"}},
+		{"?created_by=maker", http.StatusOK, []string{"goblin", "treasure"}},
+		{"?created_by=pirate,%20maker", http.StatusOK, []string{"goblin", "treasure"}},
+		{"?created_by=elf", http.StatusOK, []string{}},
+		{"?created_by=pirate,nope", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := serve(t, handler, "/v1/tokens"+tt.query, nil)
+			if rec.Code != tt.code {
+				t.Fatalf("returned %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var list TokenList
+			if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, token := range list.Data {
+				got = append(got, token.ID)
+			}
+
+			if !slices.Equal(got, tt.want) || list.TotalTokens != len(tt.want) {
+				t.Errorf("got %v (total %d), want %v", got, list.TotalTokens, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"hf-api/src/pkg/cards"
+	"net/url"
+	"path"
+)
+
+// rewriteImageURL points a stored image URL at Config.ImageBaseURL, keeping
+// its path: with a base of https://cdn.example.com/hf,
+// https://img.example.com/a/card.png becomes https://cdn.example.com/hf/a/card.png.
+func (rt *router) rewriteImageURL(raw string) string {
+	base := rt.config.ImageBaseURL
+	if base == nil || raw == "" {
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	u.Path = path.Join("/", base.Path, u.Path)
+	u.RawPath = ""
+
+	return u.String()
+}
+
+// withImages returns a copy of the card ready to be sent, with its image URLs
+// rewritten. The card in the database is left untouched.
+func (rt *router) withImages(c *cards.Card) cards.Card {
+	card := *c
+
+	if rt.config.ImageBaseURL == nil {
+		return card
+	}
+
+	card.ImageURIs = c.ImageURIs.Rewrite(rt.rewriteImageURL)
+
+	if c.SmallAltImage != nil {
+		small := rt.rewriteImageURL(*c.SmallAltImage)
+		card.SmallAltImage = &small
+	}
+
+	card.Sides = make([]cards.Side, len(c.Sides))
+	for i, side := range c.Sides {
+		side.ImageURIs = side.ImageURIs.Rewrite(rt.rewriteImageURL)
+		card.Sides[i] = side
+	}
+
+	return card
+}
+
+// ParseImageBaseURL validates the value of Config.ImageBaseURL. An empty
+// string means no rewrite.
+func ParseImageBaseURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image base URL: %w", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid image base URL %q: expected an absolute URL", raw)
+	}
+
+	return u, nil
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"hf-api/src/internal/data"
+	"hf-api/src/pkg/cards"
+	"net/http"
+	"testing"
+)
+
+func TestRewriteImageURL(t *testing.T) {
+	tests := []struct {
+		base string
+		raw  string
+		want string
+	}{
+		{"", "https://img.example.com/a/card.png", "https://img.example.com/a/card.png"},
+		{"https://cdn.example.com", "https://img.example.com/a/card.png", "https://cdn.example.com/a/card.png"},
+		{"https://cdn.example.com/hf", "https://img.example.com/a/card.png", "https://cdn.example.com/hf/a/card.png"},
+		{"https://cdn.example.com/hf/", "https://img.example.com/a/card.png", "https://cdn.example.com/hf/a/card.png"},
+		{"http://localhost:8080/mirror/hf", "https://img.example.com/a/card.png?v=2", "http://localhost:8080/mirror/hf/a/card.png?v=2"},
+		{"https://cdn.example.com/hf", "https://img.example.com/a/my%20card.png", "https://cdn.example.com/hf/a/my%20card.png"},
+
+		// Relative and empty URLs have no host to replace
+		{"https://cdn.example.com/hf", "/a/card.png", "/a/card.png"},
+		{"https://cdn.example.com/hf", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.base+" "+tt.raw, func(t *testing.T) {
+			base, err := ParseImageBaseURL(tt.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rt := &router{config: Config{ImageBaseURL: base}}
+
+			if got := rt.rewriteImageURL(tt.raw); got != tt.want {
+				t.Errorf("rewriteImageURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImageBaseURL(t *testing.T) {
+	for _, raw := range []string{"cdn.example.com/hf", "/hf", "https://", "://cdn"} {
+		if _, err := ParseImageBaseURL(raw); err == nil {
+			t.Errorf("ParseImageBaseURL(%q) accepted a URL that isn't absolute", raw)
+		}
+	}
+}
+
+func TestCardImageURIs(t *testing.T) {
+	small := "https://img.example.com/small/rider.png"
+	card := cards.Card{
+		ID: "2", Name: "Beast Rider // Giant Wagon",
+		ImageURIs:     &cards.ImageURIs{Normal: "https://img.example.com/rider.png", Small: small},
+		SmallAltImage: &small,
+		Sides: []cards.Side{
+			{ImageURIs: &cards.ImageURIs{Normal: "https://img.example.com/rider.png"}},
+			{ImageURIs: &cards.ImageURIs{Normal: "https://img.example.com/wagon.png"}},
+		},
+	}
+
+	base, _ := ParseImageBaseURL("https://cdn.example.com/hf")
+	handler := NewRouterHandler(data.NewMemoryStore(cards.Database{Cards: []cards.Card{card}}), Config{ImageBaseURL: base})
+
+	rec := serve(t, handler, "/v1/cards/2", nil)
+
+	var got cards.Card
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("returned %d with %s: %v", rec.Code, rec.Body, err)
+	}
+
+	for _, tt := range []struct{ got, want string }{
+		{got.ImageURIs.Normal, "https://cdn.example.com/hf/rider.png"},
+		{got.ImageURIs.Small, "https://cdn.example.com/hf/small/rider.png"},
+		{*got.SmallAltImage, "https://cdn.example.com/hf/small/rider.png"},
+		{got.Sides[0].ImageURIs.Normal, "https://cdn.example.com/hf/rider.png"},
+		{got.Sides[1].ImageURIs.Normal, "https://cdn.example.com/hf/wagon.png"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("image URL is %q, want %q", tt.got, tt.want)
+		}
+	}
+
+	// The stored card keeps its URLs
+	if card.ImageURIs.Normal != "https://img.example.com/rider.png" || card.Sides[1].ImageURIs.Normal != "https://img.example.com/wagon.png" {
+		t.Errorf("the stored card was rewritten: %+v", card)
+	}
+}
+
+func TestImageRedirect(t *testing.T) {
+	handler := NewRouterHandler(data.NewMemoryStore(cards.Database{Cards: []cards.Card{
+		{
+			ID: "2", Name: "Beast Rider // Giant Wagon",
+			ImageURIs: &cards.ImageURIs{Normal: "https://img.example.com/rider.png"},
+			Sides: []cards.Side{
+				{ImageURIs: &cards.ImageURIs{Normal: "https://img.example.com/rider.png"}},
+				{ImageURIs: &cards.ImageURIs{Normal: "https://img.example.com/wagon.png"}},
+				{},
+			},
+		},
+		{ID: "3", Name: "Blank Card", Sides: []cards.Side{{}}},
+	}}), Config{})
+
+	tests := []struct {
+		target   string
+		code     int
+		location string
+	}{
+		{"/v1/cards/2?format=image", http.StatusFound, "https://img.example.com/rider.png"},
+		{"/v1/cards/2?format=image&face=0", http.StatusFound, "https://img.example.com/rider.png"},
+		{"/v1/cards/2?format=image&face=1", http.StatusFound, "https://img.example.com/wagon.png"},
+		{"/v1/cards/named?exact=beast+rider+//+giant+wagon&format=image&face=1", http.StatusFound, "https://img.example.com/wagon.png"},
+
+		// A side without an image, and a card without any
+		{"/v1/cards/2?format=image&face=2", http.StatusNotFound, ""},
+		{"/v1/cards/3?format=image", http.StatusNotFound, ""},
+
+		// Out of range
+		{"/v1/cards/2?format=image&face=3", http.StatusBadRequest, ""},
+		{"/v1/cards/2?format=image&face=-1", http.StatusBadRequest, ""},
+		{"/v1/cards/2?format=image&face=back", http.StatusBadRequest, ""},
+		{"/v1/cards/3?format=image&face=1", http.StatusBadRequest, ""},
+
+		{"/v1/cards/nope?format=image", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serve(t, handler, tt.target, nil)
+
+			if rec.Code != tt.code || rec.Header().Get("Location") != tt.location {
+				t.Errorf("returned %d to %q, want %d to %q: %s", rec.Code, rec.Header().Get("Location"), tt.code, tt.location, rec.Body)
+			}
+		})
+	}
+
+	// The error tells which faces there are
+	rec := serve(t, handler, "/v1/cards/2?format=image&face=3", nil)
+
+	var body APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Message != "Invalid face, expected a side index between 0 and 2" {
+		t.Errorf("face=3 returned %s", rec.Body)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type accessEntryKey struct{}
+
+// accessEntry collects what handlers know about a request for its access
+// log line.
+type accessEntry struct {
+	// results is the number of results of a list response, -1 otherwise
+	results int
+}
+
+// accessLogMiddleware logs a line per request once it is served: method,
+// path, query string, status, latency and, for lists, the number of results.
+// Server errors are logged as errors.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessEntry{results: -1}
+		sw := &statusWriter{w: w, code: http.StatusOK}
+
+		ctx := context.WithValue(r.Context(), accessEntryKey{}, entry)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("query", r.URL.RawQuery),
+			slog.Int("status", sw.code),
+			slog.Duration("latency", time.Since(start)),
+		}
+		if entry.results >= 0 {
+			attrs = append(attrs, slog.Int("results", entry.results))
+		}
+
+		level := slog.LevelInfo
+		if sw.code >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	})
+}
+
+// logResults records the number of results of a response, when it is a list.
+// It is only called once the response succeeded, so errors have no count.
+func logResults(ctx context.Context, content any) {
+	entry, ok := ctx.Value(accessEntryKey{}).(*accessEntry)
+	if !ok {
+		return
+	}
+	if c, ok := content.(counted); ok {
+		entry.results = c.resultCount()
+	}
+}
+
+// counted is implemented by list responses.
+type counted interface {
+	resultCount() int
+}
+
+func (l *CardList) resultCount() int    { return l.TotalCards }
+func (l *TokenList) resultCount() int   { return l.TotalTokens }
+func (l *RelatedList) resultCount() int { return len(l.Data) }
+func (l *RulingList) resultCount() int  { return len(l.Data) }
+func (c *Catalog) resultCount() int     { return c.TotalValues }
+
+// statusWriter remembers the status code sent.
+type statusWriter struct {
+	w     http.ResponseWriter
+	code  int
+	wrote bool
+}
+
+func (s *statusWriter) Header() http.Header {
+	return s.w.Header()
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if !s.wrote {
+		s.code = code
+		s.wrote = true
+	}
+	s.w.WriteHeader(code)
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	s.wrote = true
+	return s.w.Write(p)
+}
+
+func (s *statusWriter) Flush() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
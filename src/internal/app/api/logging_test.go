@@ -0,0 +1,46 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogResults(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	tests := []struct {
+		target  string
+		code    int
+		results string
+	}{
+		{"/v1/cards/search?q=goblin", http.StatusOK, "results=2"},
+		{"/v1/cards/search?q=goblin&fields=nope", http.StatusBadRequest, ""},
+		{"/v1/cards/search?q=goblin&format=csv&rows=nope", http.StatusBadRequest, ""},
+		{"/v1/cards/search?q=goblin&page=0", http.StatusBadRequest, ""},
+	}
+
+	handler := testHandler()
+
+	for _, test := range tests {
+		buf.Reset()
+
+		rec := serve(t, handler, test.target, nil)
+		if rec.Code != test.code {
+			t.Errorf("%s: got %d, want %d", test.target, rec.Code, test.code)
+			continue
+		}
+
+		line := buf.String()
+		if test.results == "" && strings.Contains(line, "results=") {
+			t.Errorf("%s: results logged for an error: %s", test.target, line)
+		}
+		if test.results != "" && !strings.Contains(line, test.results) {
+			t.Errorf("%s: %s not logged: %s", test.target, test.results, line)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"hf-api/src/internal/logging"
+	"net/http"
+
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// requestIDMiddleware identifies every request, so a failing query can be
+// found in the logs: the ID is sent back in X-Request-ID, added to the logs
+// of the request and to error bodies. Under API Gateway its own request ID is
+// used, as it also shows up in the gateway's logs. Otherwise the caller's
+// X-Request-ID is kept when it looks like one, or a new ID is made up.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+
+		if gateway, ok := core.GetAPIGatewayV2ContextFromContext(r.Context()); ok && gateway.RequestID != "" {
+			id = gateway.RequestID
+		} else if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := logging.WithRequestID(r.Context(), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts IDs of up to 128 letters, digits, dashes,
+// underscores, dots and colons, which is what tracing tools send and is safe
+// to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
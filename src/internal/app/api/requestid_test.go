@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{"kept", "trace-1.2:3_abc", true},
+		{"longest kept", strings.Repeat("a", 128), true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("a", 129), false},
+		{"space", "trace 1", false},
+		{"newline", "trace\n1", false},
+		{"quote", `trace"1`, false},
+		{"not ASCII", "tracé", false},
+	}
+
+	handler := testHandler()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.incoming != "" {
+				header.Set(requestIDHeader, tt.incoming)
+			}
+
+			rec := serve(t, handler, "/v1/cards/search?q=goblin", header)
+			id := rec.Header().Get(requestIDHeader)
+
+			if tt.kept && id != tt.incoming {
+				t.Errorf("X-Request-ID is %q, want the incoming %q", id, tt.incoming)
+			}
+			if !tt.kept && (id == tt.incoming || !validRequestID(id) || len(id) != 32) {
+				t.Errorf("X-Request-ID is %q, want a new ID", id)
+			}
+		})
+	}
+
+	first := serve(t, handler, "/v1/cards/search?q=goblin", nil).Header().Get(requestIDHeader)
+	second := serve(t, handler, "/v1/cards/search?q=goblin", nil).Header().Get(requestIDHeader)
+
+	if first == second {
+		t.Errorf("two requests were both given %q", first)
+	}
+}
+
+func TestRequestIDFromGateway(t *testing.T) {
+	accessor := &core.RequestAccessorV2{}
+
+	req, err := accessor.EventToRequestWithContext(context.Background(), events.APIGatewayV2HTTPRequest{
+		RawPath:        "/v1/cards/search",
+		RawQueryString: "q=goblin",
+		Headers:        map[string]string{requestIDHeader: "from-the-caller"},
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RequestID: "gateway-id",
+			HTTP:      events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: http.MethodGet, Path: "/v1/cards/search"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	testHandler().ServeHTTP(rec, req)
+
+	if id := rec.Header().Get(requestIDHeader); id != "gateway-id" {
+		t.Errorf("X-Request-ID is %q, want the gateway's", id)
+	}
+}
+
+func TestRequestIDInErrors(t *testing.T) {
+	handler := testHandler()
+
+	for _, target := range []string{
+		"/v1/cards/search?q=(goblin",
+		"/v1/cards/search?q=goblin&fields=nope",
+		"/v1/cards/nope",
+	} {
+		t.Run(target, func(t *testing.T) {
+			rec := serve(t, handler, target, http.Header{requestIDHeader: {"trace-1"}})
+
+			var body APIError
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("returned %d with %s: %v", rec.Code, rec.Body, err)
+			}
+
+			if rec.Code < 400 || body.RequestID != "trace-1" {
+				t.Errorf("returned %d with request_id %q, want an error with trace-1", rec.Code, body.RequestID)
+			}
+		})
+	}
+}
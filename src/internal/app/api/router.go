@@ -3,16 +3,29 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"hf-api/src/internal/data"
+	"hf-api/src/internal/logging"
+	"io"
+	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
 type APIHandler func(ctx context.Context, req *http.Request) *APIResponse
 
+// APIResponse is what handlers return. Content and Error are sent as JSON;
+// Body, when set, is sent as is instead, with the Content-Type given in
+// Header. Header also carries any other response header, e.g. Location.
 type APIResponse struct {
 	Code    int
 	Error   error
 	Content any
+	Header  http.Header
+	Body    []byte
 }
 
 type APIRequest interface {
@@ -20,43 +33,240 @@ type APIRequest interface {
 	URL() *url.URL
 }
 
-var routes = map[string]APIHandler{
-	"GET /health":       health,
-	"GET /cards/search": search,
+// Config holds the settings handlers need at runtime.
+type Config struct {
+	// AllowDebug enables `debug=true` on searches, which exposes query
+	// internals, score explanations and timings. Keep it off in production.
+	AllowDebug bool
+
+	// ImageBaseURL replaces the scheme and host of every image URL in
+	// responses, e.g. to serve images from a mirror or a CDN. Its path is
+	// prepended to the image path. nil keeps the stored URLs.
+	ImageBaseURL *url.URL
+
+	// MaxAge is how long clients and CDNs may cache responses without
+	// revalidating them. 0 makes them revalidate every time, with the ETag
+	// or the date the data was generated.
+	MaxAge time.Duration
+
+	// AdminToken enables the admin endpoints, which must be called with
+	// `Authorization: Bearer <token>`. Empty disables them.
+	AdminToken string
+}
+
+type router struct {
+	store  data.CardStore
+	config Config
 }
 
-func NewRouterHandler() http.Handler {
+func (rt *router) routes() map[string]APIHandler {
+	return map[string]APIHandler{
+		"GET /health":             health,
+		"GET /cards/search":       rt.search,
+		"GET /cards/named":        rt.named,
+		"GET /cards/{id}":         rt.card,
+		"GET /cards/{id}/related": rt.related,
+		"GET /cards/{id}/rulings": rt.rulings,
+
+		"GET /tokens": rt.tokens,
+
+		"GET /catalog/keywords": rt.keywordCatalog,
+
+		"POST /admin/reload": rt.reload,
+		"GET /admin/metrics": rt.metrics,
+	}
+}
+
+// NewRouterHandler serves the API for the cards in store.
+func NewRouterHandler(store data.CardStore, config Config) http.Handler {
+	rt := &router{store: store, config: config}
 	mux := http.NewServeMux()
 
-	for pattern, handler := range routes {
-		mux.Handle(pattern, envelopeMiddleware(handler))
+	for pattern, handler := range rt.routes() {
+		mux.Handle(pattern, rt.snapshotMiddleware(rt.envelopeMiddleware(handler, cacheable(pattern))))
 	}
 
 	rootMux := http.NewServeMux()
 	rootMux.Handle("/v1/", http.StripPrefix("/v1", mux))
 
-	return rootMux
+	return requestIDMiddleware(accessLogMiddleware(rootMux))
 }
 
-func envelopeMiddleware(handler APIHandler) http.Handler {
+type storeKey struct{}
+
+// snapshotMiddleware pins the data a request sees: when the store can be
+// reloaded, the whole request, encoding included, runs on one snapshot.
+func (rt *router) snapshotMiddleware(next http.Handler) http.Handler {
+	snapshotter, ok := rt.store.(data.Snapshotter)
+	if !ok {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store, release := snapshotter.Acquire()
+		defer release()
+
+		ctx := context.WithValue(r.Context(), storeKey{}, store)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// storeFor returns the store to use for a request.
+func (rt *router) storeFor(ctx context.Context) data.CardStore {
+	if store, ok := ctx.Value(storeKey{}).(data.CardStore); ok {
+		return store
+	}
+	return rt.store
+}
+
+// envelopeMiddleware sends the response of a handler. Successful responses
+// of cacheable routes carry validators, and are sent as 304 Not Modified when
+// the client already has them. Responses that would fail never are.
+func (rt *router) envelopeMiddleware(handler APIHandler, cacheable bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+
+		var validators http.Header
+		if cacheable {
+			validators = rt.validators(r)
+		}
+
 		res := handler(ctx, r)
 
-		w.WriteHeader(res.Code)
+		for key, values := range res.Header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+
+		if res.Code == http.StatusOK && res.Error == nil {
+			maps.Copy(w.Header(), validators)
+		}
+
+		// Headers must all be set before WriteHeader
+		if res.Body != nil && res.Error == nil {
+			if res.Code == http.StatusOK && notModified(r, validators) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
 
-		var body any
+			w.WriteHeader(res.Code)
+			_, _ = w.Write(res.Body)
+			return
+		}
 
 		if res.Error != nil {
-			body = res.Error
-		} else if res.Content != nil {
-			body = res.Content
+			writeJSON(ctx, w, res.Code, res.Error)
+			return
+		}
+
+		if res.Content == nil {
+			w.WriteHeader(res.Code)
+			return
+		}
+
+		if fields := r.URL.Query().Get("fields"); fields != "" {
+			content, ok := res.Content.(projectable)
+			if !ok {
+				writeJSON(ctx, w, http.StatusBadRequest, &APIError{Message: "fields is only available for cards"})
+				return
+			}
+
+			projection, apiErr := parseFields(fields)
+			if apiErr != nil {
+				writeJSON(ctx, w, http.StatusBadRequest, apiErr)
+				return
+			}
+
+			content.project(projection)
 		}
 
-		if body != nil {
-			w.Header().Set("Content-Type", "application/json")
-			buf, _ := json.Marshal(body)
-			_, _ = w.Write(buf)
+		encoder, format, err := negotiate(r)
+
+		if err != nil {
+			writeJSON(ctx, w, http.StatusBadRequest, wrapError(capitalise(err.Error()), err))
+			return
+		}
+
+		if _, ok := res.Content.(cardContent); !ok && format != defaultFormat {
+			writeJSON(ctx, w, http.StatusNotAcceptable, &APIError{Message: fmt.Sprintf("The %s format is only available for cards", format)})
+			return
+		}
+
+		// Encoding can still fail on the parameters of the format
+		if res.Code == http.StatusOK && notModified(r, validators) && encoder.Encode(io.Discard, r.URL.Query(), res.Content) == nil {
+			logResults(ctx, res.Content)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", encoder.ContentType())
+
+		dw := &deferredWriter{w: w, code: res.Code}
+		err = encoder.Encode(dw, r.URL.Query(), res.Content)
+
+		if apiErr, ok := err.(*APIError); ok && !dw.wrote {
+			w.Header().Del("Content-Type")
+			writeJSON(ctx, w, http.StatusBadRequest, apiErr)
+			return
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to encode response", "format", format, "error", err)
+			return
+		}
+
+		// Only responses that were sent have results
+		logResults(ctx, res.Content)
+
+		if !dw.wrote {
+			w.WriteHeader(res.Code)
 		}
 	})
 }
+
+// writeJSON sends an error, which is never cached. APIErrors are sent with
+// the ID of the request.
+func writeJSON(ctx context.Context, w http.ResponseWriter, code int, body any) {
+	withoutValidators(w)
+
+	if apiErr, ok := body.(*APIError); ok {
+		withID := *apiErr
+		withID.RequestID = logging.RequestID(ctx)
+		body = &withID
+	}
+
+	buf, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(buf)
+}
+
+func capitalise(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// deferredWriter only sends the status code with the first write, so an
+// encoder can still reject a request before producing any output.
+type deferredWriter struct {
+	w     http.ResponseWriter
+	code  int
+	wrote bool
+}
+
+func (d *deferredWriter) Write(p []byte) (int, error) {
+	if !d.wrote {
+		d.w.WriteHeader(d.code)
+		d.wrote = true
+	}
+	return d.w.Write(p)
+}
+
+func (d *deferredWriter) Flush() {
+	if f, ok := d.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
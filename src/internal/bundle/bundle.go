@@ -0,0 +1,103 @@
+// Package bundle describes the directory gendb writes: the card database,
+// its index and a manifest tying them together.
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// SchemaVersion is the version of the bundle layout and card format this
+// build reads. Bump it whenever gendb output changes in a way older servers
+// can't read, e.g. a field added to the index mapping or to cards.Card.
+const SchemaVersion = 1
+
+// Files of a bundle. The manifest always sits next to the database.
+const (
+	ManifestName = "manifest.json"
+	DBName       = "db.gob.bin"
+	IndexName    = "index.bleve"
+)
+
+// Manifest describes how a bundle was generated, so a server can refuse
+// data it doesn't understand or that doesn't belong together.
+type Manifest struct {
+	SchemaVersion int       `json:"schema_version"`
+	SourceSHA256  string    `json:"source_sha256"`
+	Cards         int       `json:"cards"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	MappingHash   string    `json:"mapping_hash"`
+}
+
+// MappingHash identifies an index mapping, to tell whether an index was
+// built by the same gendb as the database next to it.
+func MappingHash(m mapping.IndexMapping) (string, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// ManifestPath returns where the manifest of the database at dbPath lives.
+func ManifestPath(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), ManifestName)
+}
+
+// Write writes the manifest of the database at dbPath.
+func (m *Manifest) Write(dbPath string) error {
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(ManifestPath(dbPath), append(buf, '\n'), 0o644)
+}
+
+// Parse reads a manifest, refusing schemas this build can't read.
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+
+	if m.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("bundle has schema version %d but this build reads version %d, regenerate it with the matching gendb", m.SchemaVersion, SchemaVersion)
+	}
+
+	return &m, nil
+}
+
+// CheckCards verifies that the database has as many cards as the manifest
+// lists.
+func (m *Manifest) CheckCards(cards int) error {
+	if cards != m.Cards {
+		return fmt.Errorf("bundle manifest lists %d cards but the database has %d", m.Cards, cards)
+	}
+	return nil
+}
+
+// CheckMapping verifies that an index was built with the mapping the
+// manifest describes.
+func (m *Manifest) CheckMapping(index mapping.IndexMapping) error {
+	hash, err := MappingHash(index)
+	if err != nil {
+		return err
+	}
+
+	if hash != m.MappingHash {
+		return fmt.Errorf("index mapping %s doesn't match the bundle manifest (%s), the index comes from another gendb run", hash, m.MappingHash)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package bundle
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+func TestParseRefusesOtherSchemas(t *testing.T) {
+	if _, err := Parse(fmt.Appendf(nil, `{"schema_version": %d, "cards": 3}`, SchemaVersion)); err != nil {
+		t.Fatalf("Parse refused the current schema: %v", err)
+	}
+
+	_, err := Parse(fmt.Appendf(nil, `{"schema_version": %d, "cards": 3}`, SchemaVersion+1))
+
+	want := fmt.Sprintf("bundle has schema version %d but this build reads version %d", SchemaVersion+1, SchemaVersion)
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Parse of another schema returned %v, want %q", err, want)
+	}
+}
+
+func TestParseRefusesInvalidManifests(t *testing.T) {
+	_, err := Parse([]byte(`{"schema_version": "1"`))
+
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid bundle manifest: ") {
+		t.Errorf("Parse of invalid JSON returned %v", err)
+	}
+}
+
+func TestCheckCards(t *testing.T) {
+	m := &Manifest{SchemaVersion: SchemaVersion, Cards: 3}
+
+	if err := m.CheckCards(3); err != nil {
+		t.Fatalf("CheckCards refused the listed count: %v", err)
+	}
+
+	err := m.CheckCards(2)
+
+	want := "bundle manifest lists 3 cards but the database has 2"
+	if err == nil || err.Error() != want {
+		t.Errorf("CheckCards(2) returned %v, want %q", err, want)
+	}
+}
+
+func TestCheckMapping(t *testing.T) {
+	built := bleve.NewIndexMapping()
+	hash, err := MappingHash(built)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manifest{SchemaVersion: SchemaVersion, MappingHash: hash}
+
+	if err := m.CheckMapping(built); err != nil {
+		t.Fatalf("CheckMapping refused the same mapping: %v", err)
+	}
+
+	other := bleve.NewIndexMapping()
+	other.DefaultAnalyzer = "en"
+	otherHash, _ := MappingHash(other)
+
+	err = m.CheckMapping(other)
+
+	want := fmt.Sprintf("index mapping %s doesn't match the bundle manifest (%s), the index comes from another gendb run", otherHash, hash)
+	if err == nil || err.Error() != want {
+		t.Errorf("CheckMapping of another mapping returned %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,188 @@
+// Package config reads the settings shared by the commands: where the card
+// database and its index live, and how to serve them.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"hf-api/src/internal/bundle"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config locates the database and sets how it is served. Each setting comes
+// from a flag, then from an environment variable, then from the defaults
+// given to Parse.
+type Config struct {
+	// DBPath is the gob database (-db, DB_PATH), with its manifest next to
+	// it.
+	DBPath string
+	// IndexPath is the bleve index directory (-index, INDEX_PATH).
+	IndexPath string
+	// IndexMode is where the index comes from (-index-mode, INDEX_MODE):
+	// IndexOnDisk opens IndexPath, IndexInMemory and IndexRoaring build it
+	// from the database at startup, so the index doesn't need to be shipped.
+	IndexMode string
+	// BundlePath is a directory written by gendb (-bundle, BUNDLE_PATH).
+	// When set, it overrides DBPath and IndexPath with the files inside it.
+	BundlePath string
+	// ListenAddr is the address the HTTP server listens on (-listen,
+	// LISTEN_ADDR). Lambda ignores it.
+	ListenAddr string
+	// CacheSize is how many search results are cached (-cache-size,
+	// CACHE_SIZE). 0 disables the cache.
+	CacheSize int
+	// CacheTTL is how long a search result is cached at most (-cache-ttl,
+	// CACHE_TTL). 0 keeps results until they are evicted or the data
+	// changes.
+	CacheTTL time.Duration
+	// CacheMaxAge is how long clients and CDNs may cache responses
+	// (-cache-max-age, CACHE_MAX_AGE). 0 makes them revalidate every time.
+	CacheMaxAge time.Duration
+	// AllowDebug enables `debug=true` on searches (-debug-queries,
+	// DEBUG_QUERIES).
+	AllowDebug bool
+	// ImageBaseURL serves images from a mirror or a CDN (-image-base-url,
+	// IMAGE_BASE_URL), e.g. https://cdn.example.com/hf. Empty keeps the
+	// stored URLs.
+	ImageBaseURL string
+	// AdminToken enables the admin endpoints (-admin-token, ADMIN_TOKEN).
+	// Empty disables them. Prefer the environment, flags show up in ps.
+	AdminToken string
+	// ReloadInterval polls the database files and reloads them when they
+	// change (-reload-interval, RELOAD_INTERVAL), e.g. 5s. 0 disables it.
+	// Lambda ignores it.
+	ReloadInterval time.Duration
+}
+
+const (
+	DefaultBundlePath = "bundle"
+	DefaultListenAddr = ":8080"
+	DefaultCacheSize  = 1000
+	DefaultCacheTTL   = 10 * time.Minute
+	DefaultMaxAge     = time.Minute
+)
+
+const (
+	IndexOnDisk   = "disk"
+	IndexInMemory = "memory"
+	// IndexRoaring replaces bleve with roaring bitmaps built at startup. It
+	// is experimental: results are the same, but relevance is database order.
+	IndexRoaring = "roaring"
+)
+
+var IndexModes = []string{IndexOnDisk, IndexInMemory, IndexRoaring}
+
+// Defaults serves the bundle in the working directory, which is how the
+// Lambda package is laid out. Debug searches are off.
+func Defaults() Config {
+	return Config{
+		DBPath:      filepath.Join(DefaultBundlePath, bundle.DBName),
+		IndexPath:   filepath.Join(DefaultBundlePath, bundle.IndexName),
+		IndexMode:   IndexOnDisk,
+		ListenAddr:  DefaultListenAddr,
+		CacheSize:   DefaultCacheSize,
+		CacheTTL:    DefaultCacheTTL,
+		CacheMaxAge: DefaultMaxAge,
+	}
+}
+
+// Parse reads the configuration from the command line of the running
+// program and the environment, like flag.Parse, and returns the arguments
+// left after the flags. It prints the usage and exits on errors.
+func Parse(defaults Config) (Config, []string) {
+	cfg := defaults
+	envErr := cfg.fromEnv()
+	fs := cfg.flagSet(os.Args[0], flag.ExitOnError)
+
+	if envErr != nil {
+		fmt.Fprintln(fs.Output(), envErr)
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	fs.Parse(os.Args[1:])
+
+	if !slices.Contains(IndexModes, cfg.IndexMode) {
+		fmt.Fprintf(fs.Output(), "invalid index mode %q, expected one of %s\n", cfg.IndexMode, strings.Join(IndexModes, ", "))
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if cfg.BundlePath != "" {
+		cfg.DBPath = filepath.Join(cfg.BundlePath, bundle.DBName)
+		cfg.IndexPath = filepath.Join(cfg.BundlePath, bundle.IndexName)
+	}
+
+	return cfg, fs.Args()
+}
+
+func (c *Config) flagSet(name string, handling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, handling)
+	fs.StringVar(&c.DBPath, "db", c.DBPath, "gob database, with its manifest next to it (env DB_PATH)")
+	fs.StringVar(&c.IndexPath, "index", c.IndexPath, "bleve index directory (env INDEX_PATH)")
+	fs.StringVar(&c.IndexMode, "index-mode", c.IndexMode, "where the index comes from: "+strings.Join(IndexModes, ", ")+" (env INDEX_MODE)")
+	fs.StringVar(&c.BundlePath, "bundle", c.BundlePath, "bundle directory, overrides -db and -index (env BUNDLE_PATH)")
+	fs.StringVar(&c.ListenAddr, "listen", c.ListenAddr, "HTTP listen address (env LISTEN_ADDR)")
+	fs.IntVar(&c.CacheSize, "cache-size", c.CacheSize, "number of search results cached, 0 to disable (env CACHE_SIZE)")
+	fs.DurationVar(&c.CacheTTL, "cache-ttl", c.CacheTTL, "how long a search result is cached at most, 0 for no limit (env CACHE_TTL)")
+	fs.DurationVar(&c.CacheMaxAge, "cache-max-age", c.CacheMaxAge, "how long clients may cache responses, 0 to always revalidate (env CACHE_MAX_AGE)")
+	fs.BoolVar(&c.AllowDebug, "debug-queries", c.AllowDebug, "allow debug=true on searches (env DEBUG_QUERIES)")
+	fs.StringVar(&c.ImageBaseURL, "image-base-url", c.ImageBaseURL, "serve images from this mirror or CDN (env IMAGE_BASE_URL)")
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, "bearer token enabling the admin endpoints, empty to disable (env ADMIN_TOKEN)")
+	fs.DurationVar(&c.ReloadInterval, "reload-interval", c.ReloadInterval, "poll the database files and reload them when they change, 0 to disable (env RELOAD_INTERVAL)")
+
+	return fs
+}
+
+func (c *Config) fromEnv() error {
+	for name, field := range map[string]*string{
+		"DB_PATH":        &c.DBPath,
+		"INDEX_PATH":     &c.IndexPath,
+		"INDEX_MODE":     &c.IndexMode,
+		"BUNDLE_PATH":    &c.BundlePath,
+		"LISTEN_ADDR":    &c.ListenAddr,
+		"IMAGE_BASE_URL": &c.ImageBaseURL,
+		"ADMIN_TOKEN":    &c.AdminToken,
+	} {
+		if v, ok := os.LookupEnv(name); ok {
+			*field = v
+		}
+	}
+
+	if v, ok := os.LookupEnv("CACHE_SIZE"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid CACHE_SIZE %q, expected a number of results", v)
+		}
+		c.CacheSize = n
+	}
+
+	for name, field := range map[string]*time.Duration{
+		"CACHE_TTL":       &c.CacheTTL,
+		"CACHE_MAX_AGE":   &c.CacheMaxAge,
+		"RELOAD_INTERVAL": &c.ReloadInterval,
+	} {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q, expected a duration like 10m", name, v)
+			}
+			*field = d
+		}
+	}
+
+	if v, ok := os.LookupEnv("DEBUG_QUERIES"); ok {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid DEBUG_QUERIES %q, expected true or false", v)
+		}
+		c.AllowDebug = allow
+	}
+
+	return nil
+}
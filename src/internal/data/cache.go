@@ -0,0 +1,201 @@
+package data
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStats counts the searches of every CachedStore, published with expvar
+// as "search_cache": hits, misses, and evictions of entries that were least
+// recently used or expired.
+var CacheStats = expvar.NewMap("search_cache")
+
+// ErrReloadUnsupported is returned by stores that forward reloads to a store
+// that can't be reloaded.
+var ErrReloadUnsupported = errors.New("the store can't be reloaded")
+
+// CachedStore remembers the results of recent searches. Entries are keyed by
+// the version of the data, so a reload never serves stale results, then by
+// the query tree, the order and the page. Searches with Explain are
+// always run, as their plan and timings are what's asked for.
+//
+// Cached results are shared between requests and must not be modified.
+type CachedStore struct {
+	CardStore
+	cache *searchCache
+}
+
+// NewCachedStore caches up to size searches of store, each for at most ttl.
+// A ttl of 0 keeps entries until they are evicted or the data changes.
+func NewCachedStore(store CardStore, size int, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		CardStore: store,
+		cache: &searchCache{
+			size:    size,
+			ttl:     ttl,
+			order:   list.New(),
+			entries: map[string]*list.Element{},
+		},
+	}
+}
+
+// WithCache wraps store in a CachedStore, unless size is 0 or less.
+func WithCache(store CardStore, size int, ttl time.Duration) CardStore {
+	if size <= 0 {
+		return store
+	}
+	return NewCachedStore(store, size, ttl)
+}
+
+func (s *CachedStore) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	if req.Explain {
+		return s.CardStore.Search(ctx, req)
+	}
+
+	// The JSON of the tree is exact, unlike query.String: it tells a quoted
+	// phrase from a value containing quotes
+	tree, err := json.Marshal(req.Query)
+	if err != nil {
+		return s.CardStore.Search(ctx, req)
+	}
+
+	stats := s.CardStore.Stats()
+	data := dataVersion{version: stats.Version, loadedAt: stats.LoadedAt}
+	key := strings.Join([]string{
+		string(tree),
+		req.Order,
+		strconv.Itoa(req.Offset),
+		strconv.Itoa(req.Size),
+	}, "\x00")
+
+	if result, ok := s.cache.get(data, key); ok {
+		CacheStats.Add("hits", 1)
+		return result, nil
+	}
+	CacheStats.Add("misses", 1)
+
+	result, err := s.CardStore.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.put(data, key, result)
+
+	return result, nil
+}
+
+// Acquire pins a snapshot of the store when it has them, and caches its
+// searches in the same cache.
+func (s *CachedStore) Acquire() (CardStore, func()) {
+	snapshotter, ok := s.CardStore.(Snapshotter)
+	if !ok {
+		return s, func() {}
+	}
+
+	store, release := snapshotter.Acquire()
+	return &CachedStore{CardStore: store, cache: s.cache}, release
+}
+
+// Reload reloads the store, or returns ErrReloadUnsupported. The cache needs
+// no clearing, the new data comes with a new version.
+func (s *CachedStore) Reload() (Stats, error) {
+	reloader, ok := s.CardStore.(Reloader)
+	if !ok {
+		return Stats{}, ErrReloadUnsupported
+	}
+	return reloader.Reload()
+}
+
+// dataVersion identifies the data a result was searched in. Versions are
+// hashes, so the load time tells which data is newer.
+type dataVersion struct {
+	version  string
+	loadedAt time.Time
+}
+
+// searchCache is an LRU cache of search results for one version of the data.
+// Entries of other versions can't be hit again, so they are all dropped when
+// newer data comes in. Results of older data, from requests that were still
+// running on it, are ignored.
+type searchCache struct {
+	size int
+	ttl  time.Duration
+
+	mu   sync.Mutex
+	data dataVersion
+	// order holds the entries, most recently used first
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	result  *SearchResult
+	expires time.Time
+}
+
+func (c *searchCache) get(data dataVersion, key string) (*SearchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if data.version != c.data.version {
+		return nil, false
+	}
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.result, true
+}
+
+func (c *searchCache) put(data dataVersion, key string, result *SearchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if data.loadedAt.Before(c.data.loadedAt) {
+		return
+	}
+
+	if data.version != c.data.version {
+		c.order.Init()
+		clear(c.entries)
+	}
+	c.data = data
+
+	entry := &cacheEntry{key: key, result: result, expires: time.Now().Add(c.ttl)}
+
+	// Another request searched the same thing meanwhile
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *searchCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+	CacheStats.Add("evictions", 1)
+}
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"hf-api/src/internal/query"
+	"hf-api/src/pkg/cards"
+	"testing"
+	"time"
+)
+
+// countingStore counts the searches that reach it.
+type countingStore struct {
+	*MemoryStore
+	stats    Stats
+	searches int
+}
+
+func (s *countingStore) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	s.searches++
+	return s.MemoryStore.Search(ctx, req)
+}
+
+func (s *countingStore) Stats() Stats {
+	return s.stats
+}
+
+func newCountingStore(version string, loadedAt time.Time) *countingStore {
+	return &countingStore{
+		MemoryStore: NewMemoryStore(cards.Database{Cards: []cards.Card{{ID: "1", Name: "Goblin Guide"}}}),
+		stats:       Stats{Version: version, LoadedAt: loadedAt},
+	}
+}
+
+func TestCachedStoreKeysOnExactQuery(t *testing.T) {
+	inner := newCountingStore("v1", time.Now())
+	store := NewCachedStore(inner, 10, 0)
+
+	// Only Quoted tells them apart: they print as oracle:foo\"bar and
+	// oracle:"foo\"bar", and search words and a phrase
+	unquoted := &query.Filter{Key: "oracle", Operator: ":", Value: `foo"bar`}
+	phrase := &query.Filter{Key: "oracle", Operator: ":", Value: `foo"bar`, Quoted: true}
+
+	for _, n := range []query.Node{unquoted, phrase, unquoted, phrase} {
+		if _, err := store.Search(context.Background(), SearchRequest{Query: n, Size: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if inner.searches != 2 {
+		t.Errorf("%d searches reached the store, want 2", inner.searches)
+	}
+}
+
+func TestCachedStoreIgnoresOlderData(t *testing.T) {
+	now := time.Now()
+	old := newCountingStore("old", now.Add(-time.Minute))
+	current := newCountingStore("new", now)
+
+	cache := NewCachedStore(current, 10, 0).cache
+	req := SearchRequest{Query: &query.Filter{Key: "name", Operator: ":", Value: "goblin"}, Size: 10}
+
+	search := func(inner CardStore) {
+		if _, err := (&CachedStore{CardStore: inner, cache: cache}).Search(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search(current)
+	// A request still running on the previous snapshot finishes late
+	search(old)
+	search(current)
+
+	if current.searches != 1 {
+		t.Errorf("the new data was searched %d times, want 1", current.searches)
+	}
+	if old.searches != 1 {
+		t.Errorf("the old data was searched %d times, want 1", old.searches)
+	}
+}
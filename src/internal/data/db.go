@@ -2,55 +2,258 @@ package data
 
 import (
 	"bytes"
-	_ "embed"
+	"context"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hf-api/src/internal/bundle"
+	"hf-api/src/internal/config"
+	"hf-api/src/internal/indexing"
+	"hf-api/src/internal/query"
 	"hf-api/src/pkg/cards"
+	"log/slog"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
 )
 
 const batchSize = 500
-const indexName = "index.bleve"
 
-//go:embed db.gob.bin
-var dbGob []byte
+// BleveStore serves the database generated by gendb: cards decoded from the
+// gob file, searched through the bleve index built alongside it.
+type BleveStore struct {
+	*cardSet
+	index bleve.Index
 
-var DB []cards.Card
-var Index bleve.Index
+	// loadTimings is how long each loading stage took, see LoadTimings
+	loadTimings map[string]time.Duration
+}
+
+// Load opens the store described by cfg.
+func Load(cfg config.Config) (LoadedStore, error) {
+	switch cfg.IndexMode {
+	case config.IndexInMemory:
+		return OpenInMemory(cfg.DBPath)
+	case config.IndexRoaring:
+		return OpenRoaring(cfg.DBPath)
+	}
+	return Open(cfg.DBPath, cfg.IndexPath)
+}
 
-func LoadDB() error {
-	if DB != nil {
-		return nil
+// Open loads the database from dbPath and opens the index at indexPath. The
+// store is checked against the bundle manifest before being returned, so a
+// half-written or mismatched database is never served.
+func Open(dbPath, indexPath string) (*BleveStore, error) {
+	set, manifest, dbElapsed, err := loadDatabase(dbPath)
+	if err != nil {
+		return nil, err
 	}
 
 	start := time.Now()
+	index, err := bleve.OpenUsing(indexPath, map[string]any{
+		"read_only": true,
+	})
+	end := time.Now()
+	elapsed := end.Sub(start)
+
+	if err != nil {
+		return nil, err
+	}
 
-	dec := gob.NewDecoder(bytes.NewReader(dbGob))
-	err := dec.Decode(&DB)
+	slog.Info("index opened", "path", indexPath, "elapsed", elapsed)
 
+	if err := manifest.CheckMapping(index.Mapping()); err != nil {
+		index.Close()
+		return nil, err
+	}
+
+	return newBleveStore(set, index, map[string]time.Duration{"database": dbElapsed, "index": elapsed})
+}
+
+// OpenInMemory loads the database like Open, but indexes it in memory
+// instead of opening a pre-built index, so the index doesn't need to be
+// shipped. Startup is slower, and the index lives on the heap.
+func OpenInMemory(dbPath string) (*BleveStore, error) {
+	set, _, dbElapsed, err := loadDatabase(dbPath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	start := time.Now()
+
+	mapping, err := indexing.BuildMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := bleve.NewMemOnly(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := indexing.Index(index, set.db.Cards); err != nil {
+		index.Close()
+		return nil, err
+	}
+
+	elapsed := time.Since(start)
+	slog.Info("index built in memory", "cards", len(set.db.Cards), "elapsed", elapsed)
+
+	return newBleveStore(set, index, map[string]time.Duration{"database": dbElapsed, "index": elapsed})
+}
+
+// loadDatabase decodes the database at dbPath and checks it against its
+// manifest. It returns how long that took.
+func loadDatabase(dbPath string) (*cardSet, *bundle.Manifest, time.Duration, error) {
+	start := time.Now()
+
+	if dbPath == "" {
+		return nil, nil, 0, errors.New("no database path, set BUNDLE_PATH or DB_PATH")
+	}
+
+	gobData, err := os.ReadFile(dbPath)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	manifestData, err := os.ReadFile(bundle.ManifestPath(dbPath))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("database has no bundle manifest: %w", err)
+	}
+
+	manifest, err := bundle.Parse(manifestData)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	var db cards.Database
+
+	dec := gob.NewDecoder(bytes.NewReader(gobData))
+	err = dec.Decode(&db)
+
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	if err := manifest.CheckCards(len(db.Cards)); err != nil {
+		return nil, nil, 0, err
 	}
 
+	sum := sha256.Sum256(gobData)
+	set := newCardSet(db, hex.EncodeToString(sum[:8]))
+	set.generatedAt = manifest.GeneratedAt
+
 	end := time.Now()
 	elapsed := end.Sub(start)
-	println("DB loaded in", elapsed.Milliseconds(), "ms")
-	fmt.Printf("loaded %d items in %dms\n", len(DB), elapsed.Milliseconds())
+	slog.Info("database loaded", "cards", len(db.Cards), "version", set.version, "elapsed", elapsed)
 
-	start = time.Now()
-	Index, err = bleve.OpenUsing(indexName, map[string]any{
-		"read_only": true,
-	})
-	end = time.Now()
-	elapsed = end.Sub(start)
+	return set, manifest, elapsed, nil
+}
+
+func newBleveStore(set *cardSet, index bleve.Index, loadTimings map[string]time.Duration) (*BleveStore, error) {
+	store := &BleveStore{cardSet: set, index: index, loadTimings: loadTimings}
+
+	if err := store.validate(); err != nil {
+		store.Close()
+		return nil, err
+	}
 
+	return store, nil
+}
+
+// validate checks that the database and the index belong together.
+func (s *BleveStore) validate() error {
+	if len(s.db.Cards) == 0 {
+		return errors.New("database has no cards")
+	}
+
+	count, err := s.index.DocCount()
 	if err != nil {
 		return err
 	}
 
-	println("Index loaded in", elapsed.Milliseconds(), "ms")
+	if int(count) != len(s.db.Cards) {
+		return fmt.Errorf("index has %d documents but the database has %d cards", count, len(s.db.Cards))
+	}
 
 	return nil
 }
+
+func (s *BleveStore) Close() error {
+	return s.index.Close()
+}
+
+func (s *BleveStore) LoadTimings() map[string]time.Duration {
+	return s.loadTimings
+}
+
+var bleveSortFields = map[string][]string{
+	OrderName:      {"name_exact"},
+	OrderManaValue: {"mv", "name_exact"},
+}
+
+func (s *BleveStore) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	bleveQuery := query.ToBleve(req.Query)
+	sideAware := query.HasSide(req.Query)
+
+	searchRequest := bleve.NewSearchRequestOptions(bleveQuery, req.Size, req.Offset, req.Explain)
+
+	if fields, ok := bleveSortFields[req.Order]; ok {
+		searchRequest.SortBy(fields)
+	}
+
+	// bleve can't tell which side matched, so side-aware queries fetch every
+	// candidate and check them one by one
+	if sideAware {
+		searchRequest.From = 0
+		searchRequest.Size = len(s.db.Cards)
+	}
+
+	start := time.Now()
+	results, err := s.index.SearchInContext(ctx, searchRequest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{
+		Total:   int(results.Total),
+		Hits:    []SearchHit{},
+		Plan:    bleveQuery,
+		Timings: map[string]time.Duration{"search": time.Since(start)},
+	}
+
+	start = time.Now()
+
+	for _, hit := range results.Hits {
+		id, _ := strconv.Atoi(hit.ID)
+		card := &s.db.Cards[id]
+		face := -1
+
+		if sideAware {
+			var ok bool
+			if ok, face = query.Match(req.Query, card); !ok {
+				continue
+			}
+		}
+
+		result.Hits = append(result.Hits, SearchHit{
+			Card:        card,
+			Face:        face,
+			Score:       hit.Score,
+			Explanation: hit.Expl,
+		})
+	}
+
+	if sideAware {
+		result.Total = len(result.Hits)
+		result.Hits = page(result.Hits, req.Offset, req.Size)
+		result.Timings["match"] = time.Since(start)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"context"
+	"hf-api/src/internal/query"
+	"hf-api/src/pkg/cards"
+	"time"
+)
+
+// MemoryStore searches a handful of cards without an index, by evaluating
+// queries against every card. It is meant for fixtures and tests, not for the
+// full database.
+type MemoryStore struct {
+	*cardSet
+}
+
+// NewMemoryStore serves the given cards and tokens. The version is fixed, as
+// the data never changes.
+func NewMemoryStore(db cards.Database) *MemoryStore {
+	return &MemoryStore{cardSet: newCardSet(db, "memory")}
+}
+
+func (s *MemoryStore) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	start := time.Now()
+	hits := []SearchHit{}
+
+	for i := range s.db.Cards {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		card := &s.db.Cards[i]
+		if ok, face := query.Match(req.Query, card); ok {
+			hits = append(hits, SearchHit{Card: card, Face: face, Score: 1})
+		}
+	}
+
+	sortHits(hits, req.Order)
+
+	return &SearchResult{
+		Total:   len(hits),
+		Hits:    page(hits, req.Offset, req.Size),
+		Timings: map[string]time.Duration{"match": time.Since(start)},
+	}, nil
+}
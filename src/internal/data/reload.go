@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"hf-api/src/pkg/cards"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Snapshotter is implemented by stores whose data can change while the
+// server runs. Acquire returns the data to use for a whole request; it stays
+// valid until release is called, even if a newer snapshot is swapped in.
+type Snapshotter interface {
+	Acquire() (store CardStore, release func())
+}
+
+// Reloader is implemented by stores that can load their data again.
+type Reloader interface {
+	Reload() (Stats, error)
+}
+
+// ErrReloadInProgress is returned when a reload is asked for while another
+// one is still loading.
+var ErrReloadInProgress = errors.New("a reload is already in progress")
+
+// ReloadableStore serves a store that can be replaced without a restart. A
+// reload loads the new data in full and validates it before swapping it in,
+// so a failed reload keeps the current data. Requests that acquired the old
+// data finish on it; it is closed once the last of them releases it.
+type ReloadableStore struct {
+	load      func() (LoadedStore, error)
+	current   atomic.Pointer[snapshot]
+	reloading sync.Mutex
+}
+
+type snapshot struct {
+	store  LoadedStore
+	inUse  sync.RWMutex
+	closed bool
+}
+
+// NewReloadableStore loads the first snapshot with load, which is called
+// again on every reload.
+func NewReloadableStore(load func() (LoadedStore, error)) (*ReloadableStore, error) {
+	store, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ReloadableStore{load: load}
+	s.current.Store(&snapshot{store: store})
+
+	return s, nil
+}
+
+func (s *ReloadableStore) Acquire() (CardStore, func()) {
+	for {
+		snap := s.current.Load()
+		snap.inUse.RLock()
+
+		// The snapshot was retired between the load and the lock, the next
+		// one is already in place
+		if snap.closed {
+			snap.inUse.RUnlock()
+			continue
+		}
+
+		return snap.store, snap.inUse.RUnlock
+	}
+}
+
+// Reload loads the data again and swaps it in, returning the stats of the
+// new data. Only one reload runs at a time.
+func (s *ReloadableStore) Reload() (Stats, error) {
+	if !s.reloading.TryLock() {
+		return Stats{}, ErrReloadInProgress
+	}
+	defer s.reloading.Unlock()
+
+	store, err := s.load()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	old := s.current.Swap(&snapshot{store: store})
+	go old.retire()
+
+	return store.Stats(), nil
+}
+
+// retire waits for the requests using the snapshot to finish, then closes it.
+func (snap *snapshot) retire() {
+	snap.inUse.Lock()
+	snap.closed = true
+	snap.inUse.Unlock()
+
+	if err := snap.store.Close(); err != nil {
+		slog.Error("closing the previous index failed", "version", snap.store.Version(), "error", err)
+	}
+}
+
+// The CardStore methods use the current snapshot for a single call. Requests
+// that make several calls should Acquire a snapshot instead, so they don't
+// mix data from two versions.
+
+func (s *ReloadableStore) Get(id string) (*cards.Card, bool) {
+	store, release := s.Acquire()
+	defer release()
+	return store.Get(id)
+}
+
+func (s *ReloadableStore) Named(name string) (*cards.Card, bool) {
+	store, release := s.Acquire()
+	defer release()
+	return store.Named(name)
+}
+
+func (s *ReloadableStore) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	store, release := s.Acquire()
+	defer release()
+	return store.Search(ctx, req)
+}
+
+func (s *ReloadableStore) All() []cards.Card {
+	store, release := s.Acquire()
+	defer release()
+	return store.All()
+}
+
+func (s *ReloadableStore) Tokens() []cards.CatalogToken {
+	store, release := s.Acquire()
+	defer release()
+	return store.Tokens()
+}
+
+func (s *ReloadableStore) Stats() Stats {
+	store, release := s.Acquire()
+	defer release()
+	return store.Stats()
+}
+
+func (s *ReloadableStore) Version() string {
+	store, release := s.Acquire()
+	defer release()
+	return store.Version()
+}
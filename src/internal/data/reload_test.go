@@ -0,0 +1,230 @@
+package data
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"hf-api/src/internal/bundle"
+	"hf-api/src/internal/config"
+	"hf-api/src/internal/query"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closingStore fails searches once it is closed.
+type closingStore struct {
+	*MemoryStore
+	closed atomic.Bool
+}
+
+func (s *closingStore) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	if s.closed.Load() {
+		return nil, errors.New("searched a closed store")
+	}
+	return s.MemoryStore.Search(ctx, req)
+}
+
+func (s *closingStore) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func (s *closingStore) LoadTimings() map[string]time.Duration {
+	return nil
+}
+
+// newClosingStores returns a ReloadableStore loading a new closingStore, with
+// a new version, on every reload, and the stores it loaded.
+func newClosingStores(t *testing.T) (*ReloadableStore, func() []*closingStore) {
+	t.Helper()
+
+	var mu sync.Mutex
+	loaded := []*closingStore{}
+
+	store, err := NewReloadableStore(func() (LoadedStore, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		s := &closingStore{MemoryStore: &MemoryStore{cardSet: newCardSet(fixtureCards(), strconv.Itoa(len(loaded)))}}
+		loaded = append(loaded, s)
+		return s, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, func() []*closingStore {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(loaded)
+	}
+}
+
+// Run with -race: searches keep going while the data is swapped under them.
+func TestReloadDuringSearches(t *testing.T) {
+	store, loaded := newClosingStores(t)
+	req := SearchRequest{Query: &query.Filter{Key: "name", Operator: ":", Value: "goblin"}, Size: 10}
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Go(func() {
+			for !stop.Load() {
+				snap, release := store.Acquire()
+				version := snap.Version()
+
+				// Every call on a snapshot sees the same data, even when
+				// a reload happens in between
+				for range 3 {
+					result, err := snap.Search(context.Background(), req)
+					if err != nil {
+						t.Errorf("search on version %s failed: %v", version, err)
+					} else if result.Total != 4 {
+						t.Errorf("search on version %s found %d cards, want 4", version, result.Total)
+					}
+					if snap.Version() != version {
+						t.Errorf("snapshot changed from version %s to %s", version, snap.Version())
+					}
+				}
+
+				release()
+			}
+		})
+	}
+
+	for range 20 {
+		if _, err := store.Reload(); err != nil && !errors.Is(err, ErrReloadInProgress) {
+			t.Error(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stop.Store(true)
+	wg.Wait()
+
+	stores := loaded()
+	current, release := store.Acquire()
+	defer release()
+
+	if current != stores[len(stores)-1] {
+		t.Errorf("serving version %s, want the last one loaded, %s", current.Version(), stores[len(stores)-1].Version())
+	}
+
+	// The previous snapshots are retired in the background
+	waitFor(t, "every previous snapshot to close", func() bool {
+		for _, s := range stores[:len(stores)-1] {
+			if !s.closed.Load() {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestReloadClosesAfterLastRelease(t *testing.T) {
+	store, loaded := newClosingStores(t)
+
+	first, releaseFirst := store.Acquire()
+	_, releaseAgain := store.Acquire()
+
+	if _, err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	old := loaded()[0]
+
+	releaseFirst()
+	time.Sleep(10 * time.Millisecond)
+
+	if old.closed.Load() {
+		t.Fatal("the previous snapshot was closed while still acquired")
+	}
+
+	// The request holding the old snapshot can still search it
+	if _, err := first.Search(context.Background(), SearchRequest{Query: query.IsToken, Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	releaseAgain()
+
+	waitFor(t, "the previous snapshot to close", old.closed.Load)
+
+	if current, release := store.Acquire(); current == first {
+		t.Error("a retired snapshot was acquired")
+	} else {
+		release()
+	}
+}
+
+func TestReloadKeepsDataOnBadManifest(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, bundle.DBName)
+	writeBundle(t, dbPath, bundle.SchemaVersion)
+
+	store, err := NewReloadableStore(func() (LoadedStore, error) {
+		return Load(config.Config{DBPath: dbPath, IndexMode: config.IndexRoaring})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := store.Stats()
+
+	writeBundle(t, dbPath, bundle.SchemaVersion+1)
+
+	_, err = store.Reload()
+	if err == nil {
+		t.Fatal("the reload accepted a manifest of another schema")
+	}
+
+	after := store.Stats()
+	if after.Version != before.Version || after.LoadedAt != before.LoadedAt || after.Cards != len(fixtureCards().Cards) {
+		t.Errorf("after a failed reload the stats are %+v, want %+v", after, before)
+	}
+
+	if _, ok := store.Get("a"); !ok {
+		t.Error("the previous data is no longer served")
+	}
+}
+
+// writeBundle writes the fixture cards to dbPath, with a manifest of the
+// given schema.
+func writeBundle(t *testing.T, dbPath string, schema int) {
+	t.Helper()
+
+	db := fixtureCards()
+
+	f, err := os.Create(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := gob.NewEncoder(f).Encode(&db); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &bundle.Manifest{SchemaVersion: schema, Cards: len(db.Cards), GeneratedAt: time.Now()}
+	if err := manifest.Write(dbPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if done() {
+			return
+		}
+	}
+
+	t.Fatalf("timed out waiting for %s", what)
+}
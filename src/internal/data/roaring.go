@@ -0,0 +1,196 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"hf-api/src/internal/query"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/RoaringBitmap/roaring/v2"
+)
+
+// RoaringStore is an experimental store indexing cards without bleve: a
+// roaring bitmap of cards per term, and a sorted array per numeric field.
+// It is built at startup, which is quick for a few thousand cards, and
+// searched through query.Candidates. There is no scoring, so relevance is
+// the database order.
+type RoaringStore struct {
+	*cardSet
+	index       *postings
+	loadTimings map[string]time.Duration
+}
+
+// postings implements query.Postings.
+type postings struct {
+	every   *roaring.Bitmap
+	terms   map[string]map[string]*roaring.Bitmap
+	numbers map[string][]numberPosting
+}
+
+type numberPosting struct {
+	value float64
+	doc   uint32
+}
+
+// OpenRoaring loads the database like Open, and indexes it with roaring
+// bitmaps instead of bleve.
+func OpenRoaring(dbPath string) (*RoaringStore, error) {
+	set, _, dbElapsed, err := loadDatabase(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(set.db.Cards) == 0 {
+		return nil, errors.New("database has no cards")
+	}
+
+	start := time.Now()
+	index := newPostings(set)
+	elapsed := time.Since(start)
+
+	slog.Info("roaring index built", "cards", len(set.db.Cards), "elapsed", elapsed)
+
+	return &RoaringStore{
+		cardSet:     set,
+		index:       index,
+		loadTimings: map[string]time.Duration{"database": dbElapsed, "index": elapsed},
+	}, nil
+}
+
+func newPostings(set *cardSet) *postings {
+	p := &postings{
+		every:   roaring.New(),
+		terms:   map[string]map[string]*roaring.Bitmap{},
+		numbers: map[string][]numberPosting{},
+	}
+
+	p.every.AddRange(0, uint64(len(set.db.Cards)))
+
+	for _, field := range query.IndexFields() {
+		switch field.Kind {
+		case query.IndexTerms:
+			byTerm := map[string]*roaring.Bitmap{}
+
+			for i := range set.db.Cards {
+				for _, term := range field.Terms(&set.db.Cards[i]) {
+					docs, ok := byTerm[term]
+					if !ok {
+						docs = roaring.New()
+						byTerm[term] = docs
+					}
+					docs.Add(uint32(i))
+				}
+			}
+
+			for _, docs := range byTerm {
+				docs.RunOptimize()
+			}
+
+			p.terms[field.Path] = byTerm
+		case query.IndexNumbers:
+			values := []numberPosting{}
+
+			for i := range set.db.Cards {
+				for _, v := range field.Numbers(&set.db.Cards[i]) {
+					values = append(values, numberPosting{value: v, doc: uint32(i)})
+				}
+			}
+
+			sort.Slice(values, func(i, j int) bool { return values[i].value < values[j].value })
+			p.numbers[field.Path] = values
+		}
+	}
+
+	return p
+}
+
+func (p *postings) Every() *roaring.Bitmap {
+	return p.every
+}
+
+var noDocs = roaring.New()
+
+func (p *postings) Term(path, term string) *roaring.Bitmap {
+	if docs, ok := p.terms[path][term]; ok {
+		return docs
+	}
+	return noDocs
+}
+
+func (p *postings) Range(path string, min, max *float64) *roaring.Bitmap {
+	values := p.numbers[path]
+	docs := roaring.New()
+
+	start := 0
+	if min != nil {
+		start = sort.Search(len(values), func(i int) bool { return values[i].value >= *min })
+	}
+
+	for _, v := range values[start:] {
+		if max != nil && v.value > *max {
+			break
+		}
+		docs.Add(v.doc)
+	}
+
+	return docs
+}
+
+func (s *RoaringStore) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	start := time.Now()
+	candidates, exact := query.Candidates(req.Query, s.index)
+
+	result := &SearchResult{
+		Hits:    []SearchHit{},
+		Plan:    roaringPlan{Candidates: candidates.GetCardinality(), Exact: exact},
+		Timings: map[string]time.Duration{"candidates": time.Since(start)},
+	}
+
+	start = time.Now()
+
+	for it := candidates.Iterator(); it.HasNext(); {
+		card := &s.db.Cards[it.Next()]
+		face := -1
+
+		// Checked against the query when the candidates aren't exact
+		if !exact {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			var ok bool
+			if ok, face = query.Match(req.Query, card); !ok {
+				continue
+			}
+		}
+
+		result.Hits = append(result.Hits, SearchHit{Card: card, Face: face, Score: 1})
+	}
+
+	if !exact {
+		result.Timings["match"] = time.Since(start)
+	}
+
+	sortHits(result.Hits, req.Order)
+
+	result.Total = len(result.Hits)
+	result.Hits = page(result.Hits, req.Offset, req.Size)
+
+	return result, nil
+}
+
+// roaringPlan is shown as the plan of a search with `debug=true`.
+type roaringPlan struct {
+	Candidates uint64 `json:"candidates"`
+	Exact      bool   `json:"exact"`
+}
+
+func (s *RoaringStore) Close() error {
+	return nil
+}
+
+func (s *RoaringStore) LoadTimings() map[string]time.Duration {
+	return s.loadTimings
+}
@@ -0,0 +1,360 @@
+package data
+
+import (
+	"context"
+	"hf-api/src/internal/indexing"
+	"hf-api/src/internal/query"
+	"hf-api/src/pkg/cards"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+// fixtureCards are a few cards shaped like gendb output, IDs a to i.
+func fixtureCards() cards.Database {
+	creature := func(typeLine string, subtypes []string, cost string, mv, power, toughness float64, text string) cards.Side {
+		return cards.Side{
+			Cost:      cost,
+			CardTypes: []string{"Creature"},
+			Subtypes:  subtypes,
+			TypeLine:  typeLine,
+			ManaValue: mv,
+			Power:     float(power),
+			Toughness: float(toughness),
+			TextBox:   text,
+		}
+	}
+
+	boss := creature("Legendary Creature — Goblin Noble", []string{"Goblin", "Noble"}, "{2}{R}{R}", 4, 3, 3, "Other Goblins you control get +1/+1.")
+	boss.Supertypes = []string{"Legendary"}
+
+	token := creature("Token Creature — Goblin", []string{"Goblin"}, "", 0, 1, 1, "")
+	token.Supertypes = []string{"Token"}
+	isToken := true
+
+	return cards.Database{Cards: []cards.Card{
+		{
+			ID: "a", Name: "Goblin Guide", Set: "HC1", Creator: "alice",
+			ManaValue: float(1), Colors: []string{"Red"}, Keywords: []string{"Haste"},
+			TypeLine:  "Creature — Goblin Scout",
+			ImageURIs: &cards.ImageURIs{Normal: "https://example.com/a.png"},
+			Sides: []cards.Side{
+				creature("Creature — Goblin Scout", []string{"Goblin", "Scout"}, "{R}", 1, 2, 2, "Haste\nWhenever Goblin Guide attacks, defending player reveals the top card of their library."),
+			},
+		},
+		{
+			ID: "b", Name: "Goblin Guide Jr", Set: "HC2", Creator: "bob",
+			ManaValue: float(1), Colors: []string{"Red"},
+			TypeLine: "Creature — Goblin",
+			Sides: []cards.Side{
+				creature("Creature — Goblin", []string{"Goblin"}, "{R}", 1, 1, 1, "Goblin Guide Jr can't block. When it dies, draw a card."),
+			},
+		},
+		{
+			ID: "c", Name: "Legendary Goblin Boss", Set: "HC1", Creator: "alice",
+			ManaValue: float(4), Colors: []string{"Red"},
+			TypeLine:      "Legendary Creature — Goblin Noble",
+			RulingEntries: []string{"It affects Goblin tokens too."},
+			Sides:         []cards.Side{boss},
+		},
+		{
+			ID: "d", Name: "Beast Rider // Giant Wagon", Set: "HC2", Creator: "carol",
+			ManaValue: float(2), Colors: []string{"Green"}, Keywords: []string{"Trample"},
+			TypeLine: "Creature — Beast // Artifact — Vehicle",
+			Sides: []cards.Side{
+				creature("Creature — Beast", []string{"Beast"}, "{1}{G}", 2, 2, 2, "Draw a card."),
+				{
+					CardTypes: []string{"Artifact"},
+					Subtypes:  []string{"Vehicle"},
+					TypeLine:  "Artifact — Vehicle",
+					Power:     float(6),
+					Toughness: float(6),
+					TextBox:   "Trample",
+					ImageURIs: &cards.ImageURIs{Normal: "https://example.com/d1.png"},
+				},
+			},
+		},
+		{
+			ID: "e", Name: "Card Draw Matters", Set: "HC1", Creator: "bob",
+			ManaValue: float(2), Colors: []string{"Blue"},
+			TypeLine: "Sorcery",
+			Sides: []cards.Side{{
+				Cost:      "{1}{U}",
+				CardTypes: []string{"Sorcery"},
+				TypeLine:  "Sorcery",
+				ManaValue: 2,
+				TextBox:   "Target player draws cards. Card draw is good.",
+			}},
+		},
+		{
+			ID: "f", Name: "Planeswalker Pal", Set: "HC2", Creator: "carol",
+			ManaValue: float(4), Colors: []string{"White"},
+			TypeLine: "Legendary Planeswalker — Pal",
+			Sides: []cards.Side{{
+				Cost:       "{2}{W}{W}",
+				Supertypes: []string{"Legendary"},
+				CardTypes:  []string{"Planeswalker"},
+				Subtypes:   []string{"Pal"},
+				TypeLine:   "Legendary Planeswalker — Pal",
+				ManaValue:  4,
+				Loyalty:    float(4),
+				TextBox:    "+1: You gain 2 life.",
+			}},
+		},
+		{
+			ID: "g", Name: "Goblin", Set: "HC1", Creator: "alice",
+			ManaValue: float(0), Colors: []string{"Red"},
+			TypeLine:      "Token Creature — Goblin",
+			IsActualToken: &isToken,
+			Sides:         []cards.Side{token},
+		},
+		{
+			ID: "h", Name: "Elf Warrior", Set: "HC1", Creator: "carol",
+			ManaValue: float(1), Colors: []string{"Green"},
+			TypeLine: "Creature — Elf Warrior",
+			Sides: []cards.Side{
+				creature("Creature — Elf Warrior", []string{"Elf", "Warrior"}, "{G}", 1, 2, 1, ""),
+			},
+		},
+		{
+			ID: "i", Name: "Colossal Dreadmaw", Set: "HC2", Creator: "bob",
+			ManaValue: float(6), Colors: []string{"Green"}, Keywords: []string{"Trample"},
+			TypeLine: "Creature — Dinosaur",
+			Sides: []cards.Side{
+				creature("Creature — Dinosaur", []string{"Dinosaur"}, "{4}{G}{G}", 6, 6, 6, "Trample"),
+			},
+		},
+	}}
+}
+
+// fixtureStores serves the fixture cards from every kind of store.
+func fixtureStores(t *testing.T) map[string]CardStore {
+	t.Helper()
+
+	set := newCardSet(fixtureCards(), "fixture")
+
+	mapping, err := indexing.BuildMapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := bleve.NewMemOnly(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := indexing.Index(index, set.db.Cards); err != nil {
+		t.Fatal(err)
+	}
+
+	bleveStore, err := newBleveStore(set, index, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bleveStore.Close() })
+
+	return map[string]CardStore{
+		"memory":  &MemoryStore{cardSet: set},
+		"bleve":   bleveStore,
+		"roaring": &RoaringStore{cardSet: set, index: newPostings(set)},
+	}
+}
+
+// searchIDs returns the IDs of the cards matching q, sorted, with the index
+// of the matched face after an @.
+func searchIDs(t *testing.T, store CardStore, q string) []string {
+	t.Helper()
+
+	root, issues, err := query.Parse(q)
+	if err != nil || len(issues) > 0 {
+		t.Fatalf("Parse(%q) failed: %v %+v", q, err, issues)
+	}
+
+	result, err := store.Search(context.Background(), SearchRequest{Query: root, Size: 100})
+	if err != nil {
+		t.Fatalf("Search(%q) failed: %v", q, err)
+	}
+
+	ids := []string{}
+	for _, hit := range result.Hits {
+		id := hit.Card.ID
+		if hit.Face >= 0 {
+			id += "@" + strconv.Itoa(hit.Face)
+		}
+		ids = append(ids, id)
+	}
+
+	if result.Total != len(ids) {
+		t.Errorf("Search(%q) has a total of %d for %d hits", q, result.Total, len(ids))
+	}
+
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSearchSideGroups(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// d has a creature side and a side with power 6, but not the same one
+		{"t:creature pow>5", []string{"d", "i"}},
+		{"side:(t:creature pow>5)", []string{"i@0"}},
+		{"-side:(t:creature pow>5)", []string{"a", "b", "c", "d", "e", "f", "g", "h"}},
+		{"t:goblin -side:(t:creature pow>2)", []string{"a", "b", "g"}},
+		{"side:(t:vehicle)", []string{"d@1"}},
+
+		// Every side is a creature
+		{"-side:(-t:creature)", []string{"a", "b", "c", "g", "h", "i"}},
+
+		// Nested groups are evaluated on the side of the outer one
+		{"side:(t:creature side:(pow>5))", []string{"i@0"}},
+		{"side:(t:artifact side:(pow>5))", []string{"d@1"}},
+		{"side:(t:beast) side:(pow>5)", []string{"d@0"}},
+		{"side:(pow>5) side:(t:beast)", []string{"d@1"}},
+	}
+
+	for name, store := range fixtureStores(t) {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.query, func(t *testing.T) {
+				if got := searchIDs(t, store, tt.query); !slices.Equal(got, tt.want) {
+					t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestSearchPhrases(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Quoted words must follow each other, in order. Words are stemmed
+		// and "a" only keeps its place, so e's "draws cards. Card" matches.
+		{`o:"draw a card"`, []string{"b", "d", "e"}},
+		{`o:"card draw"`, []string{"e"}},
+		{`o:"target player"`, []string{"e"}},
+		{`o:"player target"`, []string{}},
+		{`o:"draw good"`, []string{}},
+		{`"goblin guide"`, []string{"a", "b"}},
+		{`"guide goblin"`, []string{}},
+
+		// Exact names ignore case but not the rest of the name
+		{`!"Goblin Guide"`, []string{"a"}},
+		{`!"GOBLIN guide"`, []string{"a"}},
+		{`!"Goblin Guide Jr"`, []string{"b"}},
+		{`!"Goblin"`, []string{"g"}},
+		{`!"Guide"`, []string{}},
+		{`t:goblin -!"goblin guide"`, []string{"b", "c", "g"}},
+	}
+
+	for name, store := range fixtureStores(t) {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.query, func(t *testing.T) {
+				if got := searchIDs(t, store, tt.query); !slices.Equal(got, tt.want) {
+					t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// Every store must return the same cards, and the same matched faces, as
+// evaluating the query card by card.
+func TestStoresAgree(t *testing.T) {
+	queries := []string{
+		// Negation
+		"-t:goblin",
+		"-(c:red or c:green)",
+		"t:creature -o:trample",
+		"-c:red -is:token",
+
+		// Side groups
+		"side:(t:creature pow>5)",
+		"-side:(t:creature pow>5)",
+		"side:(-t:creature)",
+		"t:goblin or side:(pow>5)",
+		"-(t:goblin side:(pow<2))",
+
+		// Phrases, in word order
+		`o:"draw a card"`,
+		`o:"card draw"`,
+		`-o:"draw a card"`,
+		`"goblin guide"`,
+		`"guide goblin"`,
+
+		// Numbers
+		"pow!=2",
+		"mv!=1",
+		"loy!=4",
+		"pow>=2 pow<=3",
+		"tou<2",
+
+		// Type words in any order
+		`t:"legendary goblin"`,
+		`t:"goblin legendary"`,
+		`t:"creature goblin"`,
+		`t:"goblin noble"`,
+
+		// Flags
+		"is:token",
+		"-is:token",
+		"has:image",
+		"is:noimage",
+		"has:rulings",
+		"-has:rulings",
+
+		// Exact names
+		`!"goblin guide"`,
+		`!"GOBLIN GUIDE"`,
+		"!goblin",
+		`!"Goblin Guide Jr"`,
+		`t:goblin -!"goblin guide"`,
+
+		// Other fields
+		"c=green",
+		"c<=rg",
+		"m:rr",
+		"kw:trample",
+		"set:HC1 creator:alice",
+		"mv>=4",
+		"o:trample or o:haste",
+	}
+
+	stores := fixtureStores(t)
+	postings := stores["roaring"].(*RoaringStore).index
+	all := stores["memory"].All()
+
+	for _, q := range queries {
+		t.Run(q, func(t *testing.T) {
+			want := searchIDs(t, stores["memory"], q)
+
+			for _, name := range []string{"bleve", "roaring"} {
+				if got := searchIDs(t, stores[name], q); !slices.Equal(got, want) {
+					t.Errorf("%s found %v, memory found %v", name, got, want)
+				}
+			}
+
+			// Candidates must hold every match, and only matches when exact
+			root, _, _ := query.Parse(q)
+			candidates, exact := query.Candidates(root, postings)
+
+			for i := range all {
+				ok, _ := query.Match(root, &all[i])
+				if ok && !candidates.Contains(uint32(i)) {
+					t.Errorf("candidates leave out %s", all[i].ID)
+				}
+				if !ok && exact && candidates.Contains(uint32(i)) {
+					t.Errorf("exact candidates include %s", all[i].ID)
+				}
+			}
+		})
+	}
+}
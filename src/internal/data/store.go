@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"hf-api/src/internal/query"
+	"hf-api/src/pkg/cards"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+// CardStore gives access to a card database and searches it.
+type CardStore interface {
+	// Get returns the card with the given ID.
+	Get(id string) (*cards.Card, bool)
+	// Named returns the card with the given name, ignoring case.
+	Named(name string) (*cards.Card, bool)
+	// Search returns a page of the cards matching a query.
+	Search(ctx context.Context, req SearchRequest) (*SearchResult, error)
+	// All returns every card, tokens included. It must not be modified.
+	All() []cards.Card
+	// Tokens returns the token catalogue.
+	Tokens() []cards.CatalogToken
+	Stats() Stats
+	// Version identifies the data being served; it changes whenever the
+	// database does.
+	Version() string
+}
+
+// LoadedStore is a store loaded from a database by Load, which holds on to
+// its index until closed.
+type LoadedStore interface {
+	CardStore
+	Close() error
+	// LoadTimings returns how long loading took: "database" to decode the
+	// database, "index" to open or build the index.
+	LoadTimings() map[string]time.Duration
+}
+
+// Orders cards can be sorted by. Relevance keeps the order of the search
+// engine, and the database order when there is no scoring.
+const (
+	OrderRelevance = "relevance"
+	OrderName      = "name"
+	OrderManaValue = "mv"
+)
+
+var Orders = []string{OrderRelevance, OrderName, OrderManaValue}
+
+type SearchRequest struct {
+	Query   query.Node
+	Order   string
+	Offset  int
+	Size    int
+	Explain bool // fill in SearchHit.Explanation, when the store supports it
+}
+
+type SearchResult struct {
+	Total int
+	Hits  []SearchHit
+
+	// Plan is what the store ran for the query (e.g. the bleve query), for
+	// debugging. nil when the store has nothing to show.
+	Plan    any
+	Timings map[string]time.Duration
+}
+
+type SearchHit struct {
+	Card *cards.Card
+	// Face is the index of the side that satisfied a side-aware query, -1
+	// otherwise.
+	Face        int
+	Score       float64
+	Explanation *search.Explanation
+}
+
+type Stats struct {
+	Cards    int       `json:"cards"`
+	Tokens   int       `json:"tokens"`
+	Version  string    `json:"version"`
+	LoadedAt time.Time `json:"loaded_at"`
+	// GeneratedAt is when the database was generated, from its manifest.
+	// Zero when it has none.
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+}
+
+// cardSet implements the lookups shared by every store.
+type cardSet struct {
+	db          cards.Database
+	byID        map[string]int
+	byName      map[string]int
+	version     string
+	loadedAt    time.Time
+	generatedAt time.Time
+}
+
+func newCardSet(db cards.Database, version string) *cardSet {
+	s := &cardSet{
+		db:       db,
+		byID:     make(map[string]int, len(db.Cards)),
+		byName:   make(map[string]int, len(db.Cards)),
+		version:  version,
+		loadedAt: time.Now(),
+	}
+
+	for i, c := range db.Cards {
+		s.byID[c.ID] = i
+
+		// Cards sharing a name resolve to the first one
+		if _, ok := s.byName[strings.ToLower(c.Name)]; !ok {
+			s.byName[strings.ToLower(c.Name)] = i
+		}
+	}
+
+	return s
+}
+
+func (s *cardSet) Get(id string) (*cards.Card, bool) {
+	i, ok := s.byID[id]
+	if !ok {
+		return nil, false
+	}
+	return &s.db.Cards[i], true
+}
+
+func (s *cardSet) Named(name string) (*cards.Card, bool) {
+	i, ok := s.byName[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, false
+	}
+	return &s.db.Cards[i], true
+}
+
+func (s *cardSet) All() []cards.Card {
+	return s.db.Cards
+}
+
+func (s *cardSet) Tokens() []cards.CatalogToken {
+	return s.db.Tokens
+}
+
+func (s *cardSet) Stats() Stats {
+	return Stats{
+		Cards:       len(s.db.Cards),
+		Tokens:      len(s.db.Tokens),
+		Version:     s.version,
+		LoadedAt:    s.loadedAt,
+		GeneratedAt: s.generatedAt,
+	}
+}
+
+func (s *cardSet) Version() string {
+	return s.version
+}
+
+// sortHits sorts hits for stores without an order of their own. Relevance
+// keeps them as they are.
+func sortHits(hits []SearchHit, order string) {
+	switch order {
+	case OrderName:
+		sort.SliceStable(hits, func(i, j int) bool {
+			return strings.ToLower(hits[i].Card.Name) < strings.ToLower(hits[j].Card.Name)
+		})
+	case OrderManaValue:
+		sort.SliceStable(hits, func(i, j int) bool {
+			return manaValue(hits[i].Card) < manaValue(hits[j].Card)
+		})
+	}
+}
+
+func manaValue(c *cards.Card) float64 {
+	if c.ManaValue == nil {
+		return 0
+	}
+	return *c.ManaValue
+}
+
+// page returns the hits between offset and offset+size. Offsets and sizes
+// out of range give an empty page.
+func page(hits []SearchHit, offset, size int) []SearchHit {
+	if offset < 0 || size <= 0 || offset >= len(hits) {
+		return []SearchHit{}
+	}
+	return hits[offset : offset+min(size, len(hits)-offset)]
+}
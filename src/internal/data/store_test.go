@@ -0,0 +1,40 @@
+package data
+
+import (
+	"hf-api/src/pkg/cards"
+	"math"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	hits := make([]SearchHit, 25)
+	for i := range hits {
+		hits[i] = SearchHit{Card: &cards.Card{Name: string(rune('a' + i))}, Face: -1}
+	}
+
+	tests := []struct {
+		name         string
+		offset, size int
+		want         int
+	}{
+		{"first page", 0, 10, 10},
+		{"last page", 20, 10, 5},
+		{"past the end", 30, 10, 0},
+		{"negative offset", -6, 10, 0},
+		{"zero size", 0, 0, 0},
+		{"negative size", 0, -1, 0},
+		{"oversized", 10, math.MaxInt, 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := page(hits, tt.offset, tt.size)
+			if len(got) != tt.want {
+				t.Fatalf("page(%d, %d) has %d hits, want %d", tt.offset, tt.size, len(got), tt.want)
+			}
+			if tt.want > 0 && got[0].Card != hits[tt.offset].Card {
+				t.Errorf("page(%d, %d) starts at %q", tt.offset, tt.size, got[0].Card.Name)
+			}
+		})
+	}
+}
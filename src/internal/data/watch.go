@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// WatchFiles calls onChange whenever one of the paths changes, until ctx is
+// done. Paths may be files or directories, which are watched recursively.
+//
+// Files are polled every interval, and a change is only reported once they
+// have stayed the same for a whole interval, so that a database being
+// written is not picked up halfway through.
+func WatchFiles(ctx context.Context, interval time.Duration, paths []string, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := signature(paths)
+	pending := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := signature(paths)
+
+		if current != last {
+			last = current
+			pending = true
+			continue
+		}
+
+		if pending {
+			pending = false
+			onChange()
+		}
+	}
+}
+
+type fileState struct {
+	count   int
+	size    int64
+	modTime time.Time
+}
+
+// signature sums up the files under paths. Missing paths count as empty.
+func signature(paths []string) fileState {
+	var state fileState
+
+	for _, path := range paths {
+		filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+
+			state.count++
+			state.size += info.Size()
+			if info.ModTime().After(state.modTime) {
+				state.modTime = info.ModTime()
+			}
+
+			return nil
+		})
+	}
+
+	return state
+}
@@ -0,0 +1,151 @@
+// Package indexing describes how cards are indexed in bleve. gendb uses it to
+// build the index shipped in bundles, and the data package to build one in
+// memory at startup.
+package indexing
+
+import (
+	"hf-api/src/pkg/cards"
+	"strconv"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+const batchSize = 500
+const exactAnalyzer = "exact"
+
+// Document is what gets indexed for a card: the card itself, flattened,
+// plus the derived flags searched with `is:` and `has:`.
+type Document struct {
+	cards.Card
+	RulingEntries []string `json:"ruling_entries"`
+	HasImage      bool     `json:"has_image"`
+	HasRulings    bool     `json:"has_rulings"`
+}
+
+func NewDocument(c cards.Card) *Document {
+	return &Document{
+		Card:          c,
+		RulingEntries: c.RulingEntries,
+		HasImage:      c.HasImage(),
+		HasRulings:    len(c.RulingEntries) > 0,
+	}
+}
+
+// Index adds the cards to index in batches. A card's document ID is its
+// position in db, which is how stores find it back.
+func Index(index bleve.Index, db []cards.Card) error {
+	batch := index.NewBatch()
+
+	for i, c := range db {
+		if err := batch.Index(strconv.Itoa(i), NewDocument(c)); err != nil {
+			return err
+		}
+
+		if (i+1)%batchSize == 0 {
+			if err := index.Batch(batch); err != nil {
+				return err
+			}
+			batch = index.NewBatch()
+		}
+	}
+
+	if batch.Size() > 0 {
+		return index.Batch(batch)
+	}
+
+	return nil
+}
+
+// BuildMapping returns the mapping of the card index. Changing it changes
+// the mapping hash in bundle manifests, so bundles must be regenerated.
+func BuildMapping() (*mapping.IndexMappingImpl, error) {
+	m := bleve.NewIndexMapping()
+	m.DefaultAnalyzer = "en"
+
+	// Whole value, lowercased. Used for exact name matches (`!"Goblin Guide"`)
+	err := m.AddCustomAnalyzer(exactAnalyzer, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc := bleve.NewDocumentMapping()
+
+	subDoc := bleve.NewDocumentMapping()
+	doc.AddSubDocumentMapping("sides", subDoc)
+
+	// Related parts and images are only returned, never searched
+	doc.AddSubDocumentMapping("all_parts", bleve.NewDocumentDisabledMapping())
+	doc.AddSubDocumentMapping("image_uris", bleve.NewDocumentDisabledMapping())
+	subDoc.AddSubDocumentMapping("image_uris", bleve.NewDocumentDisabledMapping())
+
+	// Text fields
+	text := bleve.NewTextFieldMapping()
+
+	// Exact sub-field, indexed from the same value under its own name.
+	// The text mapping must stay first so queries on "name" keep using "en".
+	exact := bleve.NewTextFieldMapping()
+	exact.Name = "name_exact"
+	exact.Analyzer = exactAnalyzer
+
+	doc.AddFieldMappingsAt("name", text, exact)
+	doc.AddFieldMappingsAt("type_line", text)
+	doc.AddFieldMappingsAt("ruling_entries", text)
+
+	// Keyword fields
+	keyword := bleve.NewKeywordFieldMapping()
+	doc.AddFieldMappingsAt("creator", keyword)
+	doc.AddFieldMappingsAt("set", keyword)
+	doc.AddFieldMappingsAt("legality", keyword)
+	doc.AddFieldMappingsAt("colors", keyword)
+	doc.AddFieldMappingsAt("tags", keyword)
+	doc.AddFieldMappingsAt("mv_original", keyword)
+
+	// Case-insensitive keyword fields
+	exactKeyword := bleve.NewTextFieldMapping()
+	exactKeyword.Analyzer = exactAnalyzer
+	doc.AddFieldMappingsAt("keywords", exactKeyword)
+
+	// Numeric fields
+	num := bleve.NewNumericFieldMapping()
+	doc.AddFieldMappingsAt("mv", num)
+
+	// Flags
+	boolean := bleve.NewBooleanFieldMapping()
+	doc.AddFieldMappingsAt("is_actual_token", boolean)
+	doc.AddFieldMappingsAt("has_image", boolean)
+	doc.AddFieldMappingsAt("has_rulings", boolean)
+
+	// Text fields for sides
+	subDoc.AddFieldMappingsAt("cost", text)
+	subDoc.AddFieldMappingsAt("supertypes", text)
+	subDoc.AddFieldMappingsAt("card_types", text)
+	subDoc.AddFieldMappingsAt("subtypes", text)
+	subDoc.AddFieldMappingsAt("type_line", text)
+	subDoc.AddFieldMappingsAt("textbox", text)
+	subDoc.AddFieldMappingsAt("flavor_text", text)
+
+	// Keyword fields for sides
+	subDoc.AddFieldMappingsAt("mv_original", keyword)
+	subDoc.AddFieldMappingsAt("power_original", keyword)
+	subDoc.AddFieldMappingsAt("toughness_original", keyword)
+	subDoc.AddFieldMappingsAt("loyalty_original", keyword)
+
+	// Numeric fields for sides
+	subDoc.AddFieldMappingsAt("mv", num)
+	subDoc.AddFieldMappingsAt("power", num)
+	subDoc.AddFieldMappingsAt("toughness", num)
+	subDoc.AddFieldMappingsAt("loyalty", num)
+
+	m.DefaultMapping = doc
+
+	return m, nil
+}
@@ -0,0 +1,93 @@
+// Package logging sets up log/slog for the commands: JSON on Lambda, where
+// CloudWatch can query the fields, and text everywhere else. Records logged
+// with a context carry the ID of the request it belongs to.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup makes the default logger write to stderr at the level in LOG_LEVEL
+// (debug, info, warn or error; info when unset). The standard log package
+// goes through it too, at info.
+func Setup() {
+	level, levelErr := parseLevel(os.Getenv("LOG_LEVEL"))
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if onLambda() {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+
+	slog.SetDefault(slog.New(&requestHandler{handler}))
+
+	if levelErr != "" {
+		slog.Warn("invalid LOG_LEVEL, logging at info", "value", levelErr)
+	}
+}
+
+// parseLevel returns the level named by value, or info and the value when it
+// isn't a level.
+func parseLevel(value string) (slog.Level, string) {
+	if value == "" {
+		return slog.LevelInfo, ""
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return slog.LevelInfo, value
+	}
+
+	return level, ""
+}
+
+// onLambda reports whether the process runs in the Lambda runtime.
+func onLambda() bool {
+	return os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+}
+
+// Fatal logs an error and exits, like log.Fatal.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of a request, which is added
+// to every record logged with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request of ctx, or "" outside requests.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestHandler adds the request ID of the context to records, as
+// "request_id".
+type requestHandler struct {
+	slog.Handler
+}
+
+func (h *requestHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *requestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestHandler) WithGroup(name string) slog.Handler {
+	return &requestHandler{h.Handler.WithGroup(name)}
+}
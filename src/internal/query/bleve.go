@@ -0,0 +1,221 @@
+package query
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	bq "github.com/blevesearch/bleve/v2/search/query"
+)
+
+const epsilon = 10e-3 // We don't need super high precision for this
+
+// ToBleve translates a query tree into a bleve query.
+//
+// bleve flattens the sides sub-document, so Side groups cannot be expressed
+// exactly: inside them the translation only narrows down candidates (negations
+// are dropped) and results must be checked again with Match. Negated Side
+// groups match every card, as leaving out candidates would leave out matches.
+func ToBleve(n Node) bq.Query {
+	if n == nil {
+		return bleve.NewMatchNoneQuery()
+	}
+	return toBleve(n, false)
+}
+
+func toBleve(n Node, inSide bool) bq.Query {
+	switch n := n.(type) {
+	case *And:
+		queries := make([]bq.Query, len(n.Nodes))
+		for i, child := range n.Nodes {
+			queries[i] = toBleve(child, inSide)
+		}
+		return bleve.NewConjunctionQuery(queries...)
+	case *Or:
+		queries := make([]bq.Query, len(n.Nodes))
+		for i, child := range n.Nodes {
+			queries[i] = toBleve(child, inSide)
+		}
+		return bleve.NewDisjunctionQuery(queries...)
+	case *Not:
+		// Like Candidates does when it isn't exact
+		if inSide || HasSide(n.Node) {
+			return bleve.NewMatchAllQuery()
+		}
+		query := bleve.NewBooleanQuery()
+		query.AddMustNot(toBleve(n.Node, inSide))
+		return query
+	case *Side:
+		return toBleve(n.Node, true)
+	case *Filter:
+		return filterQuery(n, inSide)
+	}
+
+	return bleve.NewMatchNoneQuery()
+}
+
+func filterQuery(f *Filter, inSide bool) bq.Query {
+	spec, ok := tokenSpecs[f.Key]
+	if !ok {
+		return bleve.NewMatchNoneQuery()
+	}
+
+	fields := spec.fieldsFor(inSide)
+
+	switch spec.kind {
+	case textField:
+		if f.Operator == "!" {
+			return anyField(spec.exact, func(path string) bq.Query {
+				return termQuery(path, strings.ToLower(f.Value))
+			})
+		}
+
+		if f.Quoted && !spec.anyOrder {
+			return anyField(fields, func(path string) bq.Query {
+				query := bleve.NewMatchPhraseQuery(f.Value)
+				query.SetField(path)
+				return query
+			})
+		}
+
+		return anyField(fields, func(path string) bq.Query {
+			query := bleve.NewMatchQuery(f.Value)
+			query.SetField(path)
+			query.SetOperator(bq.MatchQueryOperatorAnd)
+			return query
+		})
+	case manaField:
+		return anyField(fields, func(path string) bq.Query {
+			query := bleve.NewMatchQuery(normaliseManaCost(f.Value))
+			query.SetField(path)
+			query.SetOperator(bq.MatchQueryOperatorAnd)
+			return query
+		})
+	case keywordField:
+		value := f.Value
+		if spec.foldCase {
+			value = strings.ToLower(value)
+		}
+		return anyField(fields, func(path string) bq.Query {
+			return termQuery(path, value)
+		})
+	case numericField:
+		num, err := strconv.ParseFloat(f.Value, 64)
+		if err != nil {
+			return anyField(spec.original, func(path string) bq.Query {
+				return termQuery(path, f.Value)
+			})
+		}
+
+		min, max := numericBounds(f.Operator, num)
+		return anyField(fields, func(path string) bq.Query {
+			inclusive := true
+			query := bleve.NewNumericRangeInclusiveQuery(min, max, &inclusive, &inclusive)
+			query.SetField(path)
+			return query
+		})
+	case colorField:
+		colors, _ := parseColors(f.Value)
+		return anyField(fields, func(path string) bq.Query {
+			return colorQuery(path, f.Operator, colors)
+		})
+	case flagField:
+		if flag, ok := lookupFlag(f.Key, f.Value); ok {
+			return flag.query()
+		}
+	}
+
+	return bleve.NewMatchNoneQuery()
+}
+
+func anyField(fields []field, build func(path string) bq.Query) bq.Query {
+	switch len(fields) {
+	case 0:
+		return bleve.NewMatchNoneQuery()
+	case 1:
+		return build(fields[0].path)
+	}
+
+	queries := make([]bq.Query, len(fields))
+	for i, f := range fields {
+		queries[i] = build(f.path)
+	}
+
+	return bleve.NewDisjunctionQuery(queries...)
+}
+
+func termQuery(path, value string) bq.Query {
+	query := bleve.NewTermQuery(value)
+	query.SetField(path)
+	return query
+}
+
+// numericBounds returns the inclusive range matching `op num`; nil means unbounded.
+func numericBounds(op string, num float64) (*float64, *float64) {
+	var min, max float64
+
+	switch op {
+	case ">":
+		min = num + epsilon
+		return &min, nil
+	case "<":
+		max = num - epsilon
+		return nil, &max
+	case ">=":
+		min = num - epsilon
+		return &min, nil
+	case "<=":
+		max = num + epsilon
+		return nil, &max
+	}
+
+	// ":" and "="
+	min = num - epsilon
+	max = num + epsilon
+	return &min, &max
+}
+
+// colorQuery compares the colours of a card against a set, Scryfall style:
+// ":" and ">=" mean "at least these", "=" exactly these, "<=" at most these.
+func colorQuery(path, op string, colors []string) bq.Query {
+	included := make([]bq.Query, 0, len(colors))
+	for _, c := range colors {
+		included = append(included, termQuery(path, c))
+	}
+
+	others := []bq.Query{}
+	for _, name := range colorNames {
+		if !slices.Contains(colors, name) {
+			others = append(others, termQuery(path, name))
+		}
+	}
+
+	query := bleve.NewBooleanQuery()
+
+	switch op {
+	case ":", ">=":
+		if len(colors) == 0 {
+			// "c:colorless" means no colours at all
+			query.AddMustNot(others...)
+			break
+		}
+		query.AddMust(included...)
+	case "=":
+		query.AddMust(included...)
+		query.AddMustNot(others...)
+	case ">":
+		query.AddMust(included...)
+		query.AddMust(bleve.NewDisjunctionQuery(others...))
+	case "<=":
+		query.AddMustNot(others...)
+	case "<":
+		query.AddMustNot(others...)
+		if len(colors) == 0 {
+			return bleve.NewMatchNoneQuery()
+		}
+		query.AddMustNot(bleve.NewConjunctionQuery(included...))
+	}
+
+	return query
+}
@@ -0,0 +1,286 @@
+package query
+
+import (
+	"hf-api/src/pkg/cards"
+	"strings"
+)
+
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	keywordField
+	numericField
+	colorField
+	manaField
+	flagField
+)
+
+// field is a single index field, along with the accessor used to evaluate it
+// outside of bleve. Side fields receive the side being evaluated; card fields
+// only look at the card.
+type field struct {
+	path   string
+	values func(c *cards.Card, s *cards.Side) []string
+	number func(c *cards.Card, s *cards.Side) *float64
+}
+
+// tokenSpec describes how a canonical token is searched.
+// card and side list the fields at each level; a token may have both (e.g. mv)
+// in which case Side groups prefer the side fields and everything else prefers
+// the card ones. original holds the raw string fields used when a numeric
+// token is given a non-numeric value (e.g. `pow:*`), and exact the
+// case-insensitive keyword fields used by the "!" operator. subject is how
+// the field reads in a description ("the type line"); plural subjects take
+// plural verbs. anyOrder text fields match quoted values word by word
+// instead of as a phrase, so `t:"legendary goblin"` finds
+// "Legendary Creature — Goblin". foldCase keyword fields are indexed
+// lowercased and compared ignoring case.
+type tokenSpec struct {
+	kind     fieldKind
+	subject  string
+	plural   bool
+	anyOrder bool
+	foldCase bool
+	card     []field
+	side     []field
+	original []field
+	exact    []field
+}
+
+var tokenSpecs = map[string]tokenSpec{
+	"name": {
+		kind:    textField,
+		subject: "the name",
+		card:    []field{{path: "name", values: func(c *cards.Card, _ *cards.Side) []string { return []string{c.Name} }}},
+		exact:   []field{{path: "name_exact", values: func(c *cards.Card, _ *cards.Side) []string { return []string{c.Name} }}},
+	},
+	"colors": {
+		kind:    colorField,
+		subject: "the colors",
+		plural:  true,
+		card:    []field{{path: "colors", values: func(c *cards.Card, _ *cards.Side) []string { return c.Colors }}},
+	},
+	"mv": {
+		kind:    numericField,
+		subject: "the mana value",
+		card:    []field{{path: "mv", number: func(c *cards.Card, _ *cards.Side) *float64 { return c.ManaValue }}},
+		side:    []field{{path: "sides.mv", number: func(_ *cards.Card, s *cards.Side) *float64 { return &s.ManaValue }}},
+		original: []field{
+			{path: "mv_original", values: func(c *cards.Card, _ *cards.Side) []string { return optional(c.ManaValueOriginal) }},
+		},
+	},
+	"mana": {
+		kind:    manaField,
+		subject: "the mana cost",
+		side:    []field{{path: "sides.cost", values: func(_ *cards.Card, s *cards.Side) []string { return []string{s.Cost} }}},
+	},
+	"type_line": {
+		kind:     textField,
+		subject:  "the type line",
+		anyOrder: true,
+		card:     []field{{path: "type_line", values: func(c *cards.Card, _ *cards.Side) []string { return []string{c.TypeLine} }}},
+		side:     []field{{path: "sides.type_line", values: func(_ *cards.Card, s *cards.Side) []string { return []string{s.TypeLine} }}},
+	},
+	"oracle": {
+		kind:    textField,
+		subject: "the rules text",
+		side:    []field{{path: "sides.textbox", values: func(_ *cards.Card, s *cards.Side) []string { return []string{s.TextBox} }}},
+	},
+	"flavor_text": {
+		kind:    textField,
+		subject: "the flavor text",
+		side:    []field{{path: "sides.flavor_text", values: func(_ *cards.Card, s *cards.Side) []string { return []string{s.FlavorText} }}},
+	},
+	"power": {
+		kind:    numericField,
+		subject: "the power",
+		side:    []field{{path: "sides.power", number: func(_ *cards.Card, s *cards.Side) *float64 { return s.Power }}},
+		original: []field{
+			{path: "sides.power_original", values: func(_ *cards.Card, s *cards.Side) []string { return optional(s.PowerOriginal) }},
+		},
+	},
+	"toughness": {
+		kind:    numericField,
+		subject: "the toughness",
+		side:    []field{{path: "sides.toughness", number: func(_ *cards.Card, s *cards.Side) *float64 { return s.Toughness }}},
+		original: []field{
+			{path: "sides.toughness_original", values: func(_ *cards.Card, s *cards.Side) []string { return optional(s.ToughnessOriginal) }},
+		},
+	},
+	"loyalty": {
+		kind:    numericField,
+		subject: "the loyalty",
+		side:    []field{{path: "sides.loyalty", number: func(_ *cards.Card, s *cards.Side) *float64 { return s.Loyalty }}},
+		original: []field{
+			{path: "sides.loyalty_original", values: func(_ *cards.Card, s *cards.Side) []string { return optional(s.LoyaltyOriginal) }},
+		},
+	},
+	"ruling": {
+		kind:    textField,
+		subject: "the rulings",
+		plural:  true,
+		card:    []field{{path: "ruling_entries", values: func(c *cards.Card, _ *cards.Side) []string { return c.RulingEntries }}},
+	},
+	"keywords": {
+		kind:     keywordField,
+		subject:  "the keywords",
+		plural:   true,
+		foldCase: true,
+		card:     []field{{path: "keywords", values: func(c *cards.Card, _ *cards.Side) []string { return c.Keywords }}},
+	},
+	"is": {
+		kind:    flagField,
+		subject: "the card",
+	},
+	"has": {
+		kind:    flagField,
+		subject: "the card",
+	},
+	"set": {
+		kind:    keywordField,
+		subject: "the set",
+		card:    []field{{path: "set", values: func(c *cards.Card, _ *cards.Side) []string { return []string{c.Set} }}},
+	},
+	"tags": {
+		kind:    keywordField,
+		subject: "the tags",
+		plural:  true,
+		card:    []field{{path: "tags", values: func(c *cards.Card, _ *cards.Side) []string { return c.Tags }}},
+	},
+	"creator": {
+		kind:    keywordField,
+		subject: "the creator",
+		card:    []field{{path: "creator", values: func(c *cards.Card, _ *cards.Side) []string { return []string{c.Creator} }}},
+	},
+}
+
+// fieldsFor returns the fields to search for a token, depending on whether it
+// is evaluated inside a Side group or against the whole card.
+func (spec tokenSpec) fieldsFor(inSide bool) []field {
+	if inSide && len(spec.side) > 0 {
+		return spec.side
+	}
+	if len(spec.card) > 0 {
+		return spec.card
+	}
+	return spec.side
+}
+
+func (f field) isSideField() bool {
+	return strings.HasPrefix(f.path, "sides.")
+}
+
+func optional(s *string) []string {
+	if s == nil {
+		return nil
+	}
+	return []string{*s}
+}
+
+// Colors
+
+var colorNames = map[string]string{
+	"W": "White",
+	"U": "Blue",
+	"B": "Black",
+	"R": "Red",
+	"G": "Green",
+	"P": "Purple",
+}
+
+var colorAliases = map[string]string{
+	"white":  "W",
+	"blue":   "U",
+	"black":  "B",
+	"red":    "R",
+	"green":  "G",
+	"purple": "P",
+
+	"colorless":  "",
+	"colourless": "",
+	"c":          "",
+
+	"azorius":  "WU",
+	"dimir":    "UB",
+	"rakdos":   "BR",
+	"gruul":    "RG",
+	"selesnya": "GW",
+	"orzhov":   "WB",
+	"izzet":    "UR",
+	"golgari":  "BG",
+	"boros":    "RW",
+	"simic":    "GU",
+
+	"bant":   "GWU",
+	"esper":  "WUB",
+	"grixis": "UBR",
+	"jund":   "BRG",
+	"naya":   "RGW",
+	"abzan":  "WBG",
+	"jeskai": "URW",
+	"sultai": "BGU",
+	"mardu":  "RWB",
+	"temur":  "GUR",
+}
+
+// parseColors turns a colour query value ("red", "rg", "gruul", "c") into the
+// set of colour names stored on cards.
+func parseColors(value string) ([]string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	letters, ok := colorAliases[value]
+	if !ok {
+		letters = strings.ToUpper(value)
+	}
+
+	seen := map[string]struct{}{}
+	colors := []string{}
+
+	for _, r := range letters {
+		name, ok := colorNames[string(r)]
+		if !ok {
+			return nil, false
+		}
+		if _, dup := seen[name]; dup {
+			continue
+		}
+		seen[name] = struct{}{}
+		colors = append(colors, name)
+	}
+
+	return colors, true
+}
+
+// Mana
+
+// normaliseManaCost turns Scryfall-style shorthand ("2RR") into the braced
+// form stored on cards ("{2}{R}{R}"). Values already using braces are kept.
+func normaliseManaCost(value string) string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	if strings.ContainsAny(value, "{}") {
+		return value
+	}
+
+	var sb strings.Builder
+	digits := ""
+
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits += string(r)
+			continue
+		}
+		if digits != "" {
+			sb.WriteString("{" + digits + "}")
+			digits = ""
+		}
+		sb.WriteString("{" + string(r) + "}")
+	}
+
+	if digits != "" {
+		sb.WriteString("{" + digits + "}")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+package query
+
+import (
+	"hf-api/src/pkg/cards"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	bq "github.com/blevesearch/bleve/v2/search/query"
+)
+
+// flag is a yes/no property of a card searched with `is:` or `has:`, indexed
+// as the boolean field path: the flag is set when the field equals value.
+// description reads after the subject ("the card is a token"), and negated
+// reads the same way for the negated flag ("the card is not a token").
+type flag struct {
+	description string
+	negated     string
+	path        string
+	value       bool
+	match       func(c *cards.Card) bool
+}
+
+// flags lists the values accepted by each flag token.
+var flags = map[string]map[string]flag{
+	"is": {
+		"token": {
+			description: "is a token",
+			negated:     "is not a token",
+			path:        "is_actual_token",
+			value:       true,
+			match:       func(c *cards.Card) bool { return c.IsActualToken != nil && *c.IsActualToken },
+		},
+		"noimage": {
+			description: "has no image",
+			negated:     "has an image",
+			path:        "has_image",
+			value:       false,
+			match:       func(c *cards.Card) bool { return !c.HasImage() },
+		},
+	},
+	"has": {
+		"image": {
+			description: "has an image",
+			negated:     "has no image",
+			path:        "has_image",
+			value:       true,
+			match:       func(c *cards.Card) bool { return c.HasImage() },
+		},
+		"rulings": {
+			description: "has rulings",
+			negated:     "has no rulings",
+			path:        "has_rulings",
+			value:       true,
+			match:       func(c *cards.Card) bool { return len(c.RulingEntries) > 0 },
+		},
+	},
+}
+
+func (f flag) query() bq.Query {
+	query := bleve.NewBoolFieldQuery(f.value)
+	query.SetField(f.path)
+	return query
+}
+
+func lookupFlag(key, value string) (flag, bool) {
+	f, ok := flags[key][strings.ToLower(value)]
+	return f, ok
+}
+
+func flagNames(key string) []string {
+	names := make([]string, 0, len(flags[key]))
+	for name := range flags[key] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsToken matches token cards. Searches leave tokens out unless asked for.
+var IsToken Node = &Filter{Key: "is", Operator: ":", Value: "token"}
+
+// Mentions reports whether the query contains a filter on the given key and
+// value, negated or not.
+func Mentions(n Node, key, value string) bool {
+	switch n := n.(type) {
+	case *And:
+		for _, child := range n.Nodes {
+			if Mentions(child, key, value) {
+				return true
+			}
+		}
+	case *Or:
+		for _, child := range n.Nodes {
+			if Mentions(child, key, value) {
+				return true
+			}
+		}
+	case *Not:
+		return Mentions(n.Node, key, value)
+	case *Side:
+		return Mentions(n.Node, key, value)
+	case *Filter:
+		return n.Key == key && strings.EqualFold(n.Value, value)
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// String returns the canonical form of a query: aliases resolved to their
+// canonical key, bare words spelled out as name filters and redundant
+// parentheses removed. Parsing the result yields the same tree for any tree
+// Parse returns, as Parse never nests an And directly in an And, or an Or in
+// an Or. Other trees parse back to an equivalent, flattened one.
+func String(n Node) string {
+	switch n := n.(type) {
+	case *And:
+		parts := make([]string, len(n.Nodes))
+		for i, child := range n.Nodes {
+			parts[i] = String(child)
+			if _, ok := child.(*Or); ok {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+		return strings.Join(parts, " ")
+	case *Or:
+		parts := make([]string, len(n.Nodes))
+		for i, child := range n.Nodes {
+			parts[i] = String(child)
+		}
+		return strings.Join(parts, " or ")
+	case *Not:
+		switch n.Node.(type) {
+		case *And, *Or:
+			return "-(" + String(n.Node) + ")"
+		}
+		return "-" + String(n.Node)
+	case *Side:
+		return "side:(" + String(n.Node) + ")"
+	case *Filter:
+		// Unquoted values stay unquoted, so they parse back the same
+		value := escapeWord(n.Value)
+		if n.Quoted || value == "" {
+			value = quote(n.Value)
+		}
+		if n.Operator == "!" {
+			return "!" + value
+		}
+		return n.Key + n.Operator + value
+	}
+
+	return ""
+}
+
+// escapeWord escapes the characters parseWord would stop at or read as a
+// quote.
+func escapeWord(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if isWordEscape(s[i]) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// Describe returns an English description of a query, e.g.
+// `cards where the type line includes "goblin" and the colors include red`.
+func Describe(n Node) string {
+	if n == nil {
+		return "no cards"
+	}
+	return "cards where " + describe(n, false)
+}
+
+func describe(n Node, nested bool) string {
+	switch n := n.(type) {
+	case *And:
+		parts := make([]string, len(n.Nodes))
+		for i, child := range n.Nodes {
+			parts[i] = describe(child, true)
+		}
+		return strings.Join(parts, " and ")
+	case *Or:
+		parts := make([]string, len(n.Nodes))
+		for i, child := range n.Nodes {
+			parts[i] = describe(child, true)
+		}
+		if nested {
+			return "(" + strings.Join(parts, " or ") + ")"
+		}
+		return strings.Join(parts, " or ")
+	case *Not:
+		if f, ok := n.Node.(*Filter); ok {
+			return describeFilter(f, true)
+		}
+		return "not (" + describe(n.Node, false) + ")"
+	case *Side:
+		return "a single side matches (" + describe(n.Node, false) + ")"
+	case *Filter:
+		return describeFilter(n, false)
+	}
+
+	return ""
+}
+
+func describeFilter(f *Filter, negated bool) string {
+	spec, ok := tokenSpecs[f.Key]
+	if !ok {
+		return fmt.Sprintf("%s %s %q", f.Key, f.Operator, f.Value)
+	}
+
+	switch spec.kind {
+	case textField:
+		if f.Operator == "!" {
+			return spec.subject + " " + verb(spec, negated, "is exactly", "are exactly") + " " + strconv.Quote(f.Value)
+		}
+		if f.Quoted && !spec.anyOrder {
+			return spec.subject + " " + verb(spec, negated, "includes the phrase", "include the phrase") + " " + strconv.Quote(f.Value)
+		}
+		return spec.subject + " " + verb(spec, negated, "includes", "include") + " " + strconv.Quote(f.Value)
+	case manaField:
+		return spec.subject + " " + verb(spec, negated, "includes", "include") + " " + normaliseManaCost(f.Value)
+	case keywordField:
+		if spec.plural {
+			return spec.subject + " " + verb(spec, negated, "", "include") + " " + f.Value
+		}
+		return spec.subject + " " + verb(spec, negated, "is", "") + " " + f.Value
+	case numericField:
+		if _, err := strconv.ParseFloat(f.Value, 64); err != nil {
+			return spec.subject + " " + verb(spec, negated, "is", "are") + " " + strconv.Quote(f.Value)
+		}
+		return spec.subject + " " + verb(spec, negated, numericVerbs[f.Operator], "") + " " + f.Value
+	case colorField:
+		colors, _ := parseColors(f.Value)
+		if len(colors) == 0 && (f.Operator == ":" || f.Operator == "=" || f.Operator == ">=") {
+			return "the card " + verb(tokenSpec{}, negated, "is", "") + " colorless"
+		}
+		names := make([]string, len(colors))
+		for i, c := range colors {
+			names[i] = strings.ToLower(c)
+		}
+		return spec.subject + " " + verb(spec, negated, "", colorVerbs[f.Operator]) + " " + joinWords(names)
+	case flagField:
+		flag, _ := lookupFlag(f.Key, f.Value)
+		if negated {
+			return spec.subject + " " + flag.negated
+		}
+		return spec.subject + " " + flag.description
+	}
+
+	return ""
+}
+
+var numericVerbs = map[string]string{
+	":":  "is",
+	"=":  "is",
+	">":  "is greater than",
+	"<":  "is less than",
+	">=": "is at least",
+	"<=": "is at most",
+}
+
+var colorVerbs = map[string]string{
+	":":  "include",
+	">=": "include",
+	"=":  "are exactly",
+	">":  "include more than",
+	"<":  "are fewer than",
+	"<=": "are at most",
+}
+
+// verb picks the singular or plural form for the subject and negates it:
+// "is" becomes "is not", "include" becomes "do not include".
+func verb(spec tokenSpec, negated bool, singular, plural string) string {
+	v := singular
+	if spec.plural {
+		v = plural
+	}
+
+	if !negated {
+		return v
+	}
+
+	first, rest, _ := strings.Cut(v, " ")
+	switch first {
+	case "is", "are":
+		return strings.TrimSpace(first + " not " + rest)
+	case "includes":
+		return strings.TrimSpace("does not include " + rest)
+	case "has":
+		return strings.TrimSpace("does not have " + rest)
+	}
+	return "do not " + v
+}
+
+func joinWords(words []string) string {
+	switch len(words) {
+	case 0:
+		return "no colors"
+	case 1:
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
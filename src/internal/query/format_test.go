@@ -0,0 +1,126 @@
+package query
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStringRoundTrip(t *testing.T) {
+	queries := []string{
+		"goblin",
+		`"goblin guide"`,
+		"!goblin",
+		`!"goblin guide"`,
+		`o:foo"bar`,
+		`o:"foo\"bar"`,
+		`o:\"foo`,
+		`o:a\b`,
+		`o:a\ b`,
+		`o:a\(b\)`,
+		"t:creature -(c:r or c:g)",
+		"side:(t:creature pow>=3)",
+	}
+
+	for _, q := range queries {
+		t.Run(q, func(t *testing.T) {
+			assertRoundTrip(t, parse(t, q))
+		})
+	}
+}
+
+// Trees built by hand, with values the parser wouldn't give as typed
+func TestStringRoundTripValues(t *testing.T) {
+	values := []string{`foo"bar`, `"foo`, `a\b`, `a b`, `(a)`, `\`}
+
+	for _, v := range values {
+		t.Run(v, func(t *testing.T) {
+			assertRoundTrip(t, &Filter{Key: "oracle", Operator: ":", Value: v})
+			assertRoundTrip(t, &Filter{Key: "oracle", Operator: ":", Value: v, Quoted: true})
+			assertRoundTrip(t, &Filter{Key: "name", Operator: "!", Value: v})
+		})
+	}
+}
+
+func parse(t *testing.T, q string) Node {
+	t.Helper()
+
+	root, issues, err := Parse(q)
+	if err != nil || len(issues) > 0 {
+		t.Fatalf("Parse(%q) failed: %v %+v", q, err, issues)
+	}
+	return root
+}
+
+func assertRoundTrip(t *testing.T, n Node) {
+	t.Helper()
+
+	s := String(n)
+	want, _ := json.Marshal(n)
+	got, _ := json.Marshal(parse(t, s))
+
+	if string(got) != string(want) {
+		t.Errorf("String gave %s, which parses to %s, want %s", s, got, want)
+	}
+}
+
+func TestDescribeFlags(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"is:token", "the card is a token"},
+		{"-is:token", "the card is not a token"},
+		{"is:noimage", "the card has no image"},
+		{"-is:noimage", "the card has an image"},
+		{"has:image", "the card has an image"},
+		{"-has:image", "the card has no image"},
+		{"-has:rulings", "the card has no rulings"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := Describe(parse(t, tt.query)); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("Describe(%q) = %q, want it to end with %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// Parse flattens nested groups of the same kind, as String drops their
+// parentheses
+func TestParseTreeShape(t *testing.T) {
+	name := func(v string) Node { return &Filter{Key: "name", Operator: ":", Value: v} }
+	a, b, c, d := name("a"), name("b"), name("c"), name("d")
+
+	tests := []struct {
+		query string
+		want  Node
+	}{
+		{"(a b) c", &And{Nodes: []Node{a, b, c}}},
+		{"a (b (c d))", &And{Nodes: []Node{a, b, c, d}}},
+		{"(a or b) or c", &Or{Nodes: []Node{a, b, c}}},
+		{"a or (b or (c or d))", &Or{Nodes: []Node{a, b, c, d}}},
+		{"(a or b) c", &And{Nodes: []Node{&Or{Nodes: []Node{a, b}}, c}}},
+		{"(a b) or c", &Or{Nodes: []Node{&And{Nodes: []Node{a, b}}, c}}},
+		{"((a b) or c) d", &And{Nodes: []Node{&Or{Nodes: []Node{&And{Nodes: []Node{a, b}}, c}}, d}}},
+		{"a -(b c)", &And{Nodes: []Node{a, &Not{Node: &And{Nodes: []Node{b, c}}}}}},
+		{"a side:(b c)", &And{Nodes: []Node{a, &Side{Node: &And{Nodes: []Node{b, c}}}}}},
+		{"(a)", a},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			root := parse(t, tt.query)
+
+			got, _ := json.Marshal(root)
+			want, _ := json.Marshal(tt.want)
+
+			if string(got) != string(want) {
+				t.Errorf("Parse(%q) = %s, want %s", tt.query, got, want)
+			}
+
+			assertRoundTrip(t, root)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+const maxSuggestions = 3
+const maxSuggestionDistance = 2
+
+// Issue points at the part of a query that could not be understood.
+// Offset counts characters (not bytes) from the start of the query.
+type Issue struct {
+	Offset      int      `json:"offset"`
+	Token       string   `json:"token"`
+	Reason      string   `json:"reason"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// SyntaxError is returned by Parse when the query cannot be parsed at all.
+type SyntaxError struct {
+	Issue
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d (%q)", e.Reason, e.Offset, e.Token)
+}
+
+func (p *parser) issueAt(start, end int, reason string) Issue {
+	end = min(end, len(p.input))
+
+	return Issue{
+		Offset: utf8.RuneCountInString(p.input[:start]),
+		Token:  p.input[start:end],
+		Reason: reason,
+	}
+}
+
+func (p *parser) errorAt(start, end int, format string, args ...any) error {
+	return &SyntaxError{Issue: p.issueAt(start, end, fmt.Sprintf(format, args...))}
+}
+
+func (p *parser) warnAt(start, end int, suggestions []string, format string, args ...any) {
+	issue := p.issueAt(start, end, fmt.Sprintf(format, args...))
+	issue.Suggestions = suggestions
+	p.warnings = append(p.warnings, issue)
+}
+
+// suggestKeys returns the known keys closest to a misspelled one,
+// e.g. "tpye" suggests "type".
+func suggestKeys(key string) []string {
+	type candidate struct {
+		alias    string
+		distance int
+	}
+
+	candidates := []candidate{}
+
+	for alias := range TokenAliasMap {
+		if d := levenshtein(key, alias); d <= maxSuggestionDistance && d < len(alias) {
+			candidates = append(candidates, candidate{alias, d})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].alias < candidates[j].alias
+	})
+
+	suggestions := []string{}
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].alias)
+	}
+
+	return suggestions
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
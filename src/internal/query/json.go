@@ -0,0 +1,44 @@
+package query
+
+import "encoding/json"
+
+// Nodes marshal with a "type" discriminator so the tree can be inspected,
+// e.g. {"type":"not","node":{"type":"filter","key":"colors",...}}
+
+func (n *And) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Nodes []Node `json:"nodes"`
+	}{"and", n.Nodes})
+}
+
+func (n *Or) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Nodes []Node `json:"nodes"`
+	}{"or", n.Nodes})
+}
+
+func (n *Not) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Node Node   `json:"node"`
+	}{"not", n.Node})
+}
+
+func (n *Side) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Node Node   `json:"node"`
+	}{"side", n.Node})
+}
+
+func (n *Filter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string `json:"type"`
+		Key      string `json:"key"`
+		Operator string `json:"operator"`
+		Value    string `json:"value"`
+		Quoted   bool   `json:"quoted,omitempty"`
+	}{"filter", n.Key, n.Operator, n.Value, n.Quoted})
+}
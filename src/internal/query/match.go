@@ -0,0 +1,300 @@
+package query
+
+import (
+	"hf-api/src/pkg/cards"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+)
+
+// textAnalyzer is the analyzer text fields are indexed with, so that Match
+// agrees with bleve on stemming and stop words.
+var textAnalyzer analysis.Analyzer = bleve.NewIndexMapping().AnalyzerNamed("en")
+
+// Match evaluates the query against a card without going through the index.
+// When the card matched through a Side group, face is the index of the side
+// that satisfied it; otherwise it is -1.
+func Match(n Node, c *cards.Card) (ok bool, face int) {
+	if n == nil {
+		return false, -1
+	}
+	return match(n, c, nil)
+}
+
+func match(n Node, c *cards.Card, side *cards.Side) (bool, int) {
+	switch n := n.(type) {
+	case *And:
+		face := -1
+		for _, child := range n.Nodes {
+			ok, childFace := match(child, c, side)
+			if !ok {
+				return false, -1
+			}
+			if face == -1 {
+				face = childFace
+			}
+		}
+		return true, face
+	case *Or:
+		for _, child := range n.Nodes {
+			if ok, face := match(child, c, side); ok {
+				return true, face
+			}
+		}
+		return false, -1
+	case *Not:
+		ok, _ := match(n.Node, c, side)
+		return !ok, -1
+	case *Side:
+		if side != nil {
+			return match(n.Node, c, side)
+		}
+		for i := range c.Sides {
+			if ok, _ := match(n.Node, c, &c.Sides[i]); ok {
+				return true, i
+			}
+		}
+		return false, -1
+	case *Filter:
+		return matchFilter(n, c, side), -1
+	}
+
+	return false, -1
+}
+
+func matchFilter(f *Filter, c *cards.Card, side *cards.Side) bool {
+	spec, ok := tokenSpecs[f.Key]
+	if !ok {
+		return false
+	}
+
+	fields := spec.fieldsFor(side != nil)
+
+	switch spec.kind {
+	case textField:
+		if f.Operator == "!" {
+			for _, fd := range spec.exact {
+				for _, v := range valuesOf(fd, c, side) {
+					if strings.EqualFold(v, f.Value) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		if f.Quoted && !spec.anyOrder {
+			return matchPhrase(fields, c, side, f.Value)
+		}
+		return matchText(fields, c, side, f.Value)
+	case manaField:
+		return matchText(fields, c, side, normaliseManaCost(f.Value))
+	case keywordField:
+		if spec.foldCase {
+			return matchKeywordFold(fields, c, side, f.Value)
+		}
+		return matchKeyword(fields, c, side, f.Value)
+	case numericField:
+		num, err := strconv.ParseFloat(f.Value, 64)
+		if err != nil {
+			return matchKeyword(spec.original, c, side, f.Value)
+		}
+
+		min, max := numericBounds(f.Operator, num)
+		for _, fd := range fields {
+			for _, v := range numbersOf(fd, c, side) {
+				if (min == nil || v >= *min) && (max == nil || v <= *max) {
+					return true
+				}
+			}
+		}
+		return false
+	case colorField:
+		colors, _ := parseColors(f.Value)
+		for _, fd := range fields {
+			if matchColors(valuesOf(fd, c, side), f.Operator, colors) {
+				return true
+			}
+		}
+		return false
+	case flagField:
+		flag, ok := lookupFlag(f.Key, f.Value)
+		return ok && flag.match(c)
+	}
+
+	return false
+}
+
+// valuesOf returns the values of a field. Side fields evaluated against the
+// whole card return the values of every side, the same way bleve flattens them.
+func valuesOf(fd field, c *cards.Card, side *cards.Side) []string {
+	if side != nil || !fd.isSideField() {
+		return fd.values(c, side)
+	}
+
+	values := []string{}
+	for i := range c.Sides {
+		values = append(values, fd.values(c, &c.Sides[i])...)
+	}
+	return values
+}
+
+func numbersOf(fd field, c *cards.Card, side *cards.Side) []float64 {
+	numbers := []float64{}
+
+	if side != nil || !fd.isSideField() {
+		if n := fd.number(c, side); n != nil {
+			numbers = append(numbers, *n)
+		}
+		return numbers
+	}
+
+	for i := range c.Sides {
+		if n := fd.number(c, &c.Sides[i]); n != nil {
+			numbers = append(numbers, *n)
+		}
+	}
+	return numbers
+}
+
+func matchText(fields []field, c *cards.Card, side *cards.Side, value string) bool {
+	wanted := analyze(value)
+	if len(wanted) == 0 {
+		return false
+	}
+
+	for _, fd := range fields {
+		terms := map[string]struct{}{}
+		for _, v := range valuesOf(fd, c, side) {
+			for _, term := range analyze(v) {
+				terms[term] = struct{}{}
+			}
+		}
+
+		found := true
+		for _, term := range wanted {
+			if _, ok := terms[term]; !ok {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPhrase looks for the analysed terms of value, in order, within a single
+// value of any of the fields. Positions left by stop words must line up too.
+func matchPhrase(fields []field, c *cards.Card, side *cards.Side, value string) bool {
+	wanted := textAnalyzer.Analyze([]byte(value))
+	if len(wanted) == 0 {
+		return false
+	}
+
+	for _, fd := range fields {
+		for _, v := range valuesOf(fd, c, side) {
+			positions := map[int]string{}
+			for _, token := range textAnalyzer.Analyze([]byte(v)) {
+				positions[token.Position] = string(token.Term)
+			}
+
+			for start, term := range positions {
+				if term != string(wanted[0].Term) {
+					continue
+				}
+
+				found := true
+				for _, token := range wanted[1:] {
+					offset := token.Position - wanted[0].Position
+					if positions[start+offset] != string(token.Term) {
+						found = false
+						break
+					}
+				}
+
+				if found {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func matchKeyword(fields []field, c *cards.Card, side *cards.Side, value string) bool {
+	for _, fd := range fields {
+		if slices.Contains(valuesOf(fd, c, side), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchKeywordFold(fields []field, c *cards.Card, side *cards.Side, value string) bool {
+	for _, fd := range fields {
+		for _, v := range valuesOf(fd, c, side) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchColors(values []string, op string, colors []string) bool {
+	hasAll := true
+	for _, c := range colors {
+		if !slices.Contains(values, c) {
+			hasAll = false
+		}
+	}
+
+	hasOthers := false
+	for _, v := range values {
+		if isColor(v) && !slices.Contains(colors, v) {
+			hasOthers = true
+		}
+	}
+
+	switch op {
+	case ":", ">=":
+		if len(colors) == 0 {
+			return !hasOthers
+		}
+		return hasAll
+	case "=":
+		return hasAll && !hasOthers
+	case ">":
+		return hasAll && hasOthers
+	case "<=":
+		return !hasOthers
+	case "<":
+		return !hasOthers && !hasAll && len(colors) > 0
+	}
+
+	return false
+}
+
+func isColor(name string) bool {
+	for _, n := range colorNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func analyze(text string) []string {
+	terms := []string{}
+	for _, token := range textAnalyzer.Analyze([]byte(text)) {
+		terms = append(terms, string(token.Term))
+	}
+	return terms
+}
@@ -0,0 +1,154 @@
+package query
+
+import (
+	"hf-api/src/pkg/cards"
+	"testing"
+
+	bq "github.com/blevesearch/bleve/v2/search/query"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+// A creature with a vehicle on its back side, which has the bigger power
+var twoSided = &cards.Card{
+	Name:     "Beast Rider // Giant Wagon",
+	TypeLine: "Creature — Beast // Artifact — Vehicle",
+	Sides: []cards.Side{
+		{TypeLine: "Creature — Beast", CardTypes: []string{"Creature"}, Subtypes: []string{"Beast"}, Power: float(2), Toughness: float(2), TextBox: "Draw a card."},
+		{TypeLine: "Artifact — Vehicle", CardTypes: []string{"Artifact"}, Subtypes: []string{"Vehicle"}, Power: float(6), Toughness: float(6), TextBox: "Trample"},
+	},
+}
+
+func TestMatchSide(t *testing.T) {
+	tests := []struct {
+		query string
+		ok    bool
+		face  int
+	}{
+		// Each filter holds on some side, but not the same one
+		{"t:creature pow>5", true, -1},
+		{"side:(t:creature pow>5)", false, -1},
+		{"-side:(t:creature pow>5)", true, -1},
+		{"side:(t:creature pow<5)", true, 0},
+		{"side:(t:vehicle pow>5)", true, 1},
+		{"side:(o:trample)", true, 1},
+
+		// Every side must be a creature
+		{"-side:(-t:creature)", false, -1},
+		{"side:(-t:creature)", true, 1},
+
+		// A nested group is evaluated on the side of the outer one
+		{"side:(t:creature side:(pow>5))", false, -1},
+		{"side:(t:artifact side:(pow>5))", true, 1},
+		{"side:(t:creature) side:(pow>5)", true, 0},
+		{"side:(pow>5) side:(t:creature)", true, 1},
+
+		// The first group that matched gives the face
+		{"side:(t:goblin) or side:(t:vehicle)", true, 1},
+		{"side:(t:beast) or side:(t:vehicle)", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			ok, face := Match(parse(t, tt.query), twoSided)
+			if ok != tt.ok || face != tt.face {
+				t.Errorf("Match(%q) = %v, %d, want %v, %d", tt.query, ok, face, tt.ok, tt.face)
+			}
+		})
+	}
+}
+
+func TestMatchPhrase(t *testing.T) {
+	guide := &cards.Card{
+		Name: "Goblin Guide",
+		Sides: []cards.Side{
+			{TypeLine: "Creature — Goblin Scout", TextBox: "Haste\nWhenever Goblin Guide attacks, draw a card."},
+		},
+	}
+
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{`o:"draw a card"`, true},
+		{`o:"DRAW A CARD"`, true},
+		{`o:"card a draw"`, false},
+		{`o:"draw card"`, false},
+		{`o:"haste whenever"`, true}, // words on either side of a line break
+		{`"goblin guide"`, true},
+		{`"guide goblin"`, false},
+		{"guide goblin", true}, // unquoted words are separate filters
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if ok, _ := Match(parse(t, tt.query), guide); ok != tt.ok {
+				t.Errorf("Match(%q) = %v, want %v", tt.query, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMatchExactName(t *testing.T) {
+	tests := []struct {
+		query string
+		name  string
+		ok    bool
+	}{
+		{`!"Goblin Guide"`, "Goblin Guide", true},
+		{`!"goblin guide"`, "Goblin Guide", true},
+		{`!"GOBLIN GUIDE"`, "Goblin Guide", true},
+		{`!"Goblin Guide"`, "Goblin Guide Jr", false},
+		{`!"Goblin Guide"`, "Young Goblin Guide", false},
+		{`!"Goblin"`, "Goblin Guide", false},
+		{"!goblin", "Goblin", true},
+		{`-!"Goblin Guide"`, "Goblin Guide Jr", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query+" "+tt.name, func(t *testing.T) {
+			card := &cards.Card{Name: tt.name, Sides: []cards.Side{{TypeLine: "Creature — Goblin"}}}
+
+			if ok, _ := Match(parse(t, tt.query), card); ok != tt.ok {
+				t.Errorf("Match(%q) on %q = %v, want %v", tt.query, tt.name, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMatchSameFace(t *testing.T) {
+	root := parse(t, "t:creature pow>5")
+
+	if ok, _ := Match(SameFace(root), twoSided); ok {
+		t.Errorf("Match(SameFace(%s)) matched sides that differ", String(root))
+	}
+
+	ok, face := Match(SameFace(parse(t, "t:vehicle pow>5")), twoSided)
+	if !ok || face != 1 {
+		t.Errorf("Match(SameFace(t:vehicle pow>5)) = %v, %d, want true, 1", ok, face)
+	}
+}
+
+func TestBleveNegatedSide(t *testing.T) {
+	tests := []struct {
+		query    string
+		matchAll bool
+	}{
+		// The group only narrows down candidates, so its negation can't
+		// rule any out
+		{"-side:(t:creature pow>5)", true},
+		{"-(t:goblin side:(pow>5))", true},
+		{"-t:goblin", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, matchAll := ToBleve(parse(t, tt.query)).(*bq.MatchAllQuery)
+			if matchAll != tt.matchAll {
+				t.Errorf("ToBleve(%q) matches every card: %v, want %v", tt.query, matchAll, tt.matchAll)
+			}
+		})
+	}
+}
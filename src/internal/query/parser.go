@@ -0,0 +1,486 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var KnownTokens = map[string][]string{
+	"name":        {"name", "n"},
+	"colors":      {"colors", "c", "color"},
+	"mv":          {"mv", "cmc"},
+	"mana":        {"mana", "m"},
+	"identity":    {"identity", "id"},
+	"type_line":   {"type_line", "type", "t"},
+	"oracle":      {"oracle", "o"},
+	"flavor_text": {"flavor_text", "ft", "flavor", "flavortext"},
+	"ruling":      {"ruling", "rulings"},
+
+	"power":           {"power", "pow"},
+	"toughness":       {"toughness", "tou", "tough"},
+	"power_toughness": {"power_toughness", "pt", "powtou"},
+	"loyalty":         {"loyalty", "loy"},
+
+	"devotion": {"devotion"},
+	"produces": {"produces"},
+
+	"keywords": {"keywords", "keyword", "kw"},
+
+	"set":     {"set", "s", "edition", "e"},
+	"tags":    {"tags", "tag"},
+	"creator": {"creator", "author"},
+
+	"format": {"format", "f"},
+	"banned": {"banned", "ban"},
+
+	"side": {"side", "face"},
+
+	"is":  {"is"},
+	"has": {"has"},
+}
+
+// Grammar, loosely following https://scryfall.com/docs/syntax
+//
+//	query   = or
+//	or      = and { "or" and }
+//	and     = { unary | "and" }
+//	unary   = [ "-" ] primary
+//	primary = "(" or ")" | "side:(" or ")" | "!" ( quoted | word ) | filter | quoted | word
+//	filter  = key operator ( quoted | word | "(" or ")" )
+//
+// In a filter's group, bare words and quoted values are values of that
+// filter rather than names, so `t:(goblin or elf)` is `t:goblin or t:elf`.
+
+var filterPrefix = regexp.MustCompile(`^(\w+)(!=|>=|<=|[:=<>])`)
+
+type parser struct {
+	input    string
+	pos      int
+	warnings []Issue
+
+	// group is the filter whose group is being parsed, nil outside of one
+	group *groupFilter
+}
+
+// groupFilter is the key and operator applied to the values of a group.
+type groupFilter struct {
+	keyAlias, op string
+}
+
+// Parse turns a search string into a query tree.
+// Terms that can't be understood (unknown keys, invalid values) are skipped
+// and reported as warnings; a *SyntaxError is returned when the query as a
+// whole can't be parsed. A nil Node is returned when no term was understood.
+func Parse(input string) (Node, []Issue, error) {
+	p := &parser{input: input}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.skipSpaces()
+	if !p.eof() {
+		return nil, nil, p.errorAt(p.pos, p.pos+1, "unexpected closing parenthesis")
+	}
+
+	return node, p.warnings, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	nodes := []Node{}
+
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		// `(a or b) or c` is a single Or, as String writes it
+		if or, ok := node.(*Or); ok {
+			nodes = append(nodes, or.Nodes...)
+		} else if node != nil {
+			nodes = append(nodes, node)
+		}
+
+		if !p.consumeKeyword("or") {
+			break
+		}
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+
+	return &Or{Nodes: nodes}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	nodes := []Node{}
+
+	for {
+		p.skipSpaces()
+
+		if p.eof() || p.peek() == ')' || p.atKeyword("or") {
+			break
+		}
+
+		if p.consumeKeyword("and") {
+			continue
+		}
+
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		// `(a b) c` is a single And, as String writes it
+		if and, ok := node.(*And); ok {
+			nodes = append(nodes, and.Nodes...)
+		} else if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+
+	return &And{Nodes: nodes}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek() == '-' && p.pos+1 < len(p.input) && !isSpace(p.input[p.pos+1]) {
+		p.pos++
+
+		node, err := p.parsePrimary()
+		if err != nil || node == nil {
+			return nil, err
+		}
+
+		return negate(node), nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	switch p.peek() {
+	case '(':
+		return p.parseGroup()
+	case '"':
+		start := p.pos
+		value, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return p.bareTerm(start, value, true), nil
+	case '!':
+		p.pos++ // !
+		if p.peek() == '"' {
+			value, err := p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+			return &Filter{Key: "name", Operator: "!", Value: value, Quoted: true}, nil
+		}
+		return &Filter{Key: "name", Operator: "!", Value: p.parseWord()}, nil
+	}
+
+	if match := filterPrefix.FindStringSubmatch(p.input[p.pos:]); match != nil {
+		return p.parseFilter(match[1], match[2])
+	}
+
+	start := p.pos
+	return p.bareTerm(start, p.parseWord(), false), nil
+}
+
+// bareTerm returns the filter for a value without a key: a name, or a value
+// of the group's filter inside one.
+func (p *parser) bareTerm(start int, value string, quoted bool) Node {
+	if p.group == nil {
+		return &Filter{Key: "name", Operator: ":", Value: value, Quoted: quoted}
+	}
+
+	return p.newFilter(start, p.group.keyAlias, p.group.op, value, quoted)
+}
+
+func (p *parser) parseGroup() (Node, error) {
+	start := p.pos
+	p.pos++ // (
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if p.peek() != ')' {
+		return nil, p.errorAt(start, start+1, "unbalanced parenthesis")
+	}
+	p.pos++ // )
+
+	return node, nil
+}
+
+func (p *parser) parseFilter(keyAlias, op string) (Node, error) {
+	start := p.pos
+	p.pos += len(keyAlias) + len(op)
+
+	if TokenAliasMap[strings.ToLower(keyAlias)] == "side" {
+		if op != ":" || p.peek() != '(' {
+			return nil, p.errorAt(start, p.pos, "%s: expects a parenthesised group", keyAlias)
+		}
+
+		node, err := p.parseGroup()
+		if err != nil || node == nil {
+			return nil, err
+		}
+
+		return &Side{Node: node}, nil
+	}
+
+	if p.peek() == '(' {
+		return p.parseFilterGroup(start, keyAlias, op)
+	}
+
+	var value string
+	var err error
+
+	quoted := p.peek() == '"'
+
+	if quoted {
+		value, err = p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		value = p.parseWord()
+	}
+
+	return p.newFilter(start, keyAlias, op, value, quoted), nil
+}
+
+// parseFilterGroup parses a group whose values belong to the filter, e.g.
+// `t:(goblin or elf)`. A negated filter negates the whole group, so
+// `t!=(goblin or elf)` is neither a goblin nor an elf.
+func (p *parser) parseFilterGroup(start int, keyAlias, op string) (Node, error) {
+	outer := p.group
+	defer func() { p.group = outer }()
+
+	_, known := tokenSpecs[TokenAliasMap[strings.ToLower(keyAlias)]]
+
+	p.group = &groupFilter{keyAlias: keyAlias, op: op}
+	if op == "!=" {
+		p.group.op = "="
+	}
+	if !known {
+		p.group = nil
+	}
+
+	warnings := len(p.warnings)
+
+	node, err := p.parseGroup()
+	if err != nil {
+		return nil, err
+	}
+
+	// Report an unknown key once for the whole group
+	if !known {
+		p.warnings = p.warnings[:warnings]
+		p.lookupKey(start, keyAlias)
+		return nil, nil
+	}
+
+	// Report `t:()` once, unless its values were already reported
+	if node == nil {
+		if len(p.warnings) == warnings {
+			p.warnAt(start, p.pos, nil, "%s%s expects a value", keyAlias, op)
+		}
+		return nil, nil
+	}
+
+	if op == "!=" {
+		return negate(node), nil
+	}
+
+	return node, nil
+}
+
+// newFilter returns the filter for keyAlias, or nil after a warning starting
+// at start when it can't be searched.
+func (p *parser) newFilter(start int, keyAlias, op, value string, quoted bool) Node {
+	key, spec, ok := p.lookupKey(start, keyAlias)
+	if !ok {
+		return nil
+	}
+
+	// An empty filter would match nothing, e.g. `t:` or `t:""`
+	if value == "" {
+		p.warnAt(start, p.pos, nil, "%s%s expects a value", keyAlias, op)
+		return nil
+	}
+
+	if reason := validateFilter(key, spec, keyAlias, op, value); reason != "" {
+		p.warnAt(start, p.pos, nil, "%s", reason)
+		return nil
+	}
+
+	if op == "!=" {
+		return &Not{Node: &Filter{Key: key, Operator: "=", Value: value, Quoted: quoted}}
+	}
+
+	return &Filter{Key: key, Operator: op, Value: value, Quoted: quoted}
+}
+
+// lookupKey returns the key and spec of keyAlias, or warns from start when
+// it is unknown or not supported.
+func (p *parser) lookupKey(start int, keyAlias string) (string, tokenSpec, bool) {
+	key, ok := TokenAliasMap[strings.ToLower(keyAlias)]
+
+	if !ok {
+		p.warnAt(start, p.pos, suggestKeys(strings.ToLower(keyAlias)), "unknown keyword %q", keyAlias)
+		return "", tokenSpec{}, false
+	}
+
+	spec, ok := tokenSpecs[key]
+
+	if !ok {
+		p.warnAt(start, p.pos, nil, "%q is not supported yet", keyAlias)
+		return "", tokenSpec{}, false
+	}
+
+	return key, spec, true
+}
+
+// validateFilter returns why a filter can't be searched, or "" if it can.
+func validateFilter(key string, spec tokenSpec, keyAlias, op, value string) string {
+	switch spec.kind {
+	case numericField:
+		if _, err := strconv.ParseFloat(value, 64); err != nil && op != ":" && op != "=" && op != "!=" {
+			return fmt.Sprintf("%s%s expects a number, got %q", keyAlias, op, value)
+		}
+	case colorField:
+		if _, ok := parseColors(value); !ok {
+			return fmt.Sprintf("unknown colour %q", value)
+		}
+	case flagField:
+		if op != ":" && op != "=" && op != "!=" {
+			return fmt.Sprintf("%s does not support the %s operator", keyAlias, op)
+		}
+		if _, ok := lookupFlag(key, value); !ok {
+			return fmt.Sprintf("%s: expects one of %s, got %q", keyAlias, strings.Join(flagNames(key), ", "), value)
+		}
+	default:
+		if op != ":" && op != "=" && op != "!=" {
+			return fmt.Sprintf("%s does not support the %s operator", keyAlias, op)
+		}
+	}
+
+	return ""
+}
+
+func (p *parser) parseQuoted() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+
+	var sb strings.Builder
+
+	for !p.eof() {
+		c := p.input[p.pos]
+		p.pos++
+
+		switch c {
+		case '\\':
+			if !p.eof() {
+				sb.WriteByte(p.input[p.pos])
+				p.pos++
+			}
+		case '"':
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", p.errorAt(start, len(p.input), "unterminated quote")
+}
+
+// parseWord reads an unquoted value, up to a space or a parenthesis. A
+// backslash escapes the next character when it would end the word, or is a
+// quote or a backslash, so String can write any value without quotes.
+func (p *parser) parseWord() string {
+	var sb strings.Builder
+
+	for !p.eof() && !isSpace(p.input[p.pos]) && p.input[p.pos] != '(' && p.input[p.pos] != ')' {
+		if p.input[p.pos] == '\\' && p.pos+1 < len(p.input) && isWordEscape(p.input[p.pos+1]) {
+			p.pos++
+		}
+		sb.WriteByte(p.input[p.pos])
+		p.pos++
+	}
+
+	return sb.String()
+}
+
+// isWordEscape reports whether c must be escaped in an unquoted value.
+func isWordEscape(c byte) bool {
+	return isSpace(c) || c == '(' || c == ')' || c == '"' || c == '\\'
+}
+
+func (p *parser) atKeyword(keyword string) bool {
+	end := p.pos + len(keyword)
+
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], keyword) {
+		return false
+	}
+
+	return end == len(p.input) || isSpace(p.input[end]) || p.input[end] == '(' || p.input[end] == ')'
+}
+
+func (p *parser) consumeKeyword(keyword string) bool {
+	p.skipSpaces()
+
+	if !p.atKeyword(keyword) {
+		return false
+	}
+
+	p.pos += len(keyword)
+	return true
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func negate(n Node) Node {
+	if not, ok := n.(*Not); ok {
+		return not.Node
+	}
+	return &Not{Node: n}
+}
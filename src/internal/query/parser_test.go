@@ -0,0 +1,95 @@
+package query
+
+import "testing"
+
+func TestParseEmptyValue(t *testing.T) {
+	tests := []struct {
+		query  string
+		offset int
+		want   string
+	}{
+		{"t:", 0, ""},
+		{`t:""`, 0, ""},
+		{"goblin t:", 7, "name:goblin"},
+		{"t:()", 0, ""},
+		{"goblin t:( )", 7, "name:goblin"},
+		{"mv>=", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			root, issues, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.query, err)
+			}
+
+			if got := String(root); got != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+
+			if len(issues) != 1 || issues[0].Offset != tt.offset || issues[0].Reason == "" {
+				t.Errorf("Parse(%q) issues = %+v, want one at offset %d", tt.query, issues, tt.offset)
+			}
+		})
+	}
+}
+
+func TestParseFilterGroup(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"t:(goblin or elf)", "type_line:goblin or type_line:elf"},
+		{"t:(goblin elf)", "type_line:goblin type_line:elf"},
+		{`o:("draw a card" or haste)`, `oracle:"draw a card" or oracle:haste`},
+		{"t:((goblin or elf) legendary)", "(type_line:goblin or type_line:elf) type_line:legendary"},
+		{"t:(goblin -elf)", "type_line:goblin -type_line:elf"},
+		{"t:(goblin or c:red)", "type_line:goblin or colors:red"},
+		{"t:(goblin) guide", "type_line:goblin name:guide"},
+		{"t!=(goblin or elf)", "-(type_line=goblin or type_line=elf)"},
+		{"pow>=(2 or 5)", "power>=2 or power>=5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			root, issues, err := Parse(tt.query)
+			if err != nil || len(issues) > 0 {
+				t.Fatalf("Parse(%q) failed: %v %+v", tt.query, err, issues)
+			}
+
+			if got := String(root); got != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterGroupIssues(t *testing.T) {
+	tests := []struct {
+		query  string
+		offset int
+		want   string
+	}{
+		// Reported once, over the whole group
+		{"foo:(goblin or elf)", 0, ""},
+		{"goblin c:(red or blurple)", 17, "name:goblin colors:red"},
+		{"pow>(2 or many)", 10, "power>2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			root, issues, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.query, err)
+			}
+
+			if got := String(root); got != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+
+			if len(issues) != 1 || issues[0].Offset != tt.offset {
+				t.Errorf("Parse(%q) issues = %+v, want one at offset %d", tt.query, issues, tt.offset)
+			}
+		})
+	}
+}
@@ -1,50 +1,59 @@
 // Code generated by codegens/codegens.go; DO NOT EDIT.
 
-package api
+package query
 
 var TokenAliasMap = map[string]string{
-	"power_toughness": "power_toughness",
-	"pt":              "power_toughness",
-	"banned":          "banned",
-	"ban":             "banned",
-	"produces":        "produces",
-	"identity":        "identity",
-	"id":              "identity",
-	"toughness":       "toughness",
-	"n":               "name",
 	"type_line":       "type_line",
-	"flavortext":      "flavor_text",
-	"colors":          "colors",
-	"color":           "colors",
 	"s":               "set",
+	"side":            "side",
+	"banned":          "banned",
+	"power_toughness": "power_toughness",
 	"format":          "format",
-	"loy":             "loyalty",
-	"mv":              "mv",
-	"flavor_text":     "flavor_text",
-	"ft":              "flavor_text",
 	"name":            "name",
-	"m":               "mana",
-	"set":             "set",
-	"type":            "type_line",
-	"edition":         "set",
-	"o":               "oracle",
-	"loyalty":         "loyalty",
-	"tags":            "tags",
-	"t":               "type_line",
+	"n":               "name",
+	"ft":              "flavor_text",
+	"has":             "has",
+	"flavortext":      "flavor_text",
 	"tou":             "toughness",
-	"mana":            "mana",
+	"o":               "oracle",
+	"rulings":         "ruling",
+	"kw":              "keywords",
 	"creator":         "creator",
-	"flavor":          "flavor_text",
+	"author":          "creator",
+	"produces":        "produces",
+	"mana":            "mana",
+	"colors":          "colors",
+	"cmc":             "mv",
+	"loy":             "loyalty",
+	"ban":             "banned",
+	"pt":              "power_toughness",
+	"m":               "mana",
 	"power":           "power",
-	"c":               "colors",
+	"keywords":        "keywords",
+	"tags":            "tags",
+	"tag":             "tags",
 	"e":               "set",
+	"is":              "is",
+	"powtou":          "power_toughness",
+	"id":              "identity",
 	"oracle":          "oracle",
-	"tag":             "tags",
-	"author":          "creator",
+	"c":               "colors",
+	"type":            "type_line",
+	"loyalty":         "loyalty",
+	"set":             "set",
 	"f":               "format",
-	"powtou":          "power_toughness",
+	"identity":        "identity",
+	"flavor":          "flavor_text",
+	"toughness":       "toughness",
+	"ruling":          "ruling",
+	"keyword":         "keywords",
 	"devotion":        "devotion",
-	"cmc":             "mv",
-	"pow":             "power",
+	"flavor_text":     "flavor_text",
 	"tough":           "toughness",
+	"face":            "side",
+	"pow":             "power",
+	"mv":              "mv",
+	"t":               "type_line",
+	"edition":         "set",
+	"color":           "colors",
 }
@@ -0,0 +1,279 @@
+package query
+
+import (
+	"hf-api/src/pkg/cards"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/RoaringBitmap/roaring/v2"
+)
+
+// Postings is an inverted index of cards, built from IndexFields, that
+// queries can run against without bleve. Documents are numbered like the
+// cards they index. Returned bitmaps must not be modified.
+type Postings interface {
+	// Every returns every document.
+	Every() *roaring.Bitmap
+	// Term returns the documents where the field holds the term.
+	Term(path, term string) *roaring.Bitmap
+	// Range returns the documents where the numeric field has a value
+	// between min and max, inclusive. nil bounds are unbounded.
+	Range(path string, min, max *float64) *roaring.Bitmap
+}
+
+type IndexKind int
+
+const (
+	// IndexTerms fields hold terms, looked up with Postings.Term
+	IndexTerms IndexKind = iota
+	// IndexNumbers fields hold numbers, looked up with Postings.Range
+	IndexNumbers
+)
+
+// IndexField is a field Postings must index for Candidates to work. Terms
+// returns the terms of a card as they are searched: analysed for text,
+// lowercased where case is ignored. Side fields hold the values of every
+// side, flattened the same way bleve does.
+type IndexField struct {
+	Path    string
+	Kind    IndexKind
+	Terms   func(c *cards.Card) []string
+	Numbers func(c *cards.Card) []float64
+}
+
+// IndexFields lists the fields searched by tokenSpecs and flags, each once.
+func IndexFields() []IndexField {
+	fields := []IndexField{}
+	seen := map[string]bool{}
+
+	add := func(f IndexField) {
+		if !seen[f.Path] {
+			seen[f.Path] = true
+			fields = append(fields, f)
+		}
+	}
+
+	for _, spec := range tokenSpecs {
+		for _, fd := range slices.Concat(spec.card, spec.side) {
+			switch spec.kind {
+			case textField, manaField:
+				add(termsField(fd, analyze))
+			case numericField:
+				add(IndexField{Path: fd.path, Kind: IndexNumbers, Numbers: func(c *cards.Card) []float64 {
+					return numbersOf(fd, c, nil)
+				}})
+			case keywordField, colorField:
+				if spec.foldCase {
+					add(termsField(fd, lowercase))
+				} else {
+					add(termsField(fd, nil))
+				}
+			}
+		}
+
+		for _, fd := range spec.original {
+			add(termsField(fd, nil))
+		}
+
+		for _, fd := range spec.exact {
+			add(termsField(fd, lowercase))
+		}
+	}
+
+	for _, byValue := range flags {
+		for _, f := range byValue {
+			add(IndexField{Path: f.path, Kind: IndexTerms, Terms: func(c *cards.Card) []string {
+				// The flag and the field agree when the flag is set
+				return []string{strconv.FormatBool(f.match(c) == f.value)}
+			}})
+		}
+	}
+
+	slices.SortFunc(fields, func(a, b IndexField) int { return strings.Compare(a.Path, b.Path) })
+
+	return fields
+}
+
+// termsField indexes the values of a field, split into terms by split. nil
+// keeps each value whole.
+func termsField(fd field, split func(string) []string) IndexField {
+	return IndexField{Path: fd.path, Kind: IndexTerms, Terms: func(c *cards.Card) []string {
+		values := valuesOf(fd, c, nil)
+		if split == nil {
+			return values
+		}
+
+		terms := []string{}
+		for _, v := range values {
+			terms = append(terms, split(v)...)
+		}
+		return terms
+	}}
+}
+
+func lowercase(value string) []string {
+	return []string{strings.ToLower(value)}
+}
+
+// Candidates evaluates a query against postings. When exact is false the
+// result only narrows down candidates, which must be checked with Match:
+// like in ToBleve, Side groups are evaluated on flattened sides, and phrases
+// only require their words.
+func Candidates(n Node, p Postings) (docs *roaring.Bitmap, exact bool) {
+	if n == nil {
+		return roaring.New(), true
+	}
+	return candidates(n, p, false)
+}
+
+func candidates(n Node, p Postings, inSide bool) (*roaring.Bitmap, bool) {
+	switch n := n.(type) {
+	case *And:
+		docs, exact := p.Every(), true
+		for _, child := range n.Nodes {
+			childDocs, childExact := candidates(child, p, inSide)
+			docs = roaring.And(docs, childDocs)
+			exact = exact && childExact
+		}
+		return docs, exact
+	case *Or:
+		all, exact := make([]*roaring.Bitmap, len(n.Nodes)), true
+		for i, child := range n.Nodes {
+			var childExact bool
+			all[i], childExact = candidates(child, p, inSide)
+			exact = exact && childExact
+		}
+		return roaring.FastOr(all...), exact
+	case *Not:
+		if inSide {
+			return p.Every(), false
+		}
+		docs, exact := candidates(n.Node, p, inSide)
+		// Leaving out candidates would leave out matches
+		if !exact {
+			return p.Every(), false
+		}
+		return roaring.AndNot(p.Every(), docs), true
+	case *Side:
+		docs, _ := candidates(n.Node, p, true)
+		return docs, false
+	case *Filter:
+		return filterCandidates(n, p, inSide)
+	}
+
+	return roaring.New(), true
+}
+
+func filterCandidates(f *Filter, p Postings, inSide bool) (*roaring.Bitmap, bool) {
+	spec, ok := tokenSpecs[f.Key]
+	if !ok {
+		return roaring.New(), true
+	}
+
+	fields := spec.fieldsFor(inSide)
+
+	switch spec.kind {
+	case textField:
+		if f.Operator == "!" {
+			return anyPosting(spec.exact, func(path string) *roaring.Bitmap {
+				return p.Term(path, strings.ToLower(f.Value))
+			}), true
+		}
+
+		docs := allTerms(p, fields, analyze(f.Value))
+		return docs, !f.Quoted || spec.anyOrder
+	case manaField:
+		return allTerms(p, fields, analyze(normaliseManaCost(f.Value))), true
+	case keywordField:
+		value := f.Value
+		if spec.foldCase {
+			value = strings.ToLower(value)
+		}
+		return anyPosting(fields, func(path string) *roaring.Bitmap {
+			return p.Term(path, value)
+		}), true
+	case numericField:
+		num, err := strconv.ParseFloat(f.Value, 64)
+		if err != nil {
+			return anyPosting(spec.original, func(path string) *roaring.Bitmap {
+				return p.Term(path, f.Value)
+			}), true
+		}
+
+		min, max := numericBounds(f.Operator, num)
+		return anyPosting(fields, func(path string) *roaring.Bitmap {
+			return p.Range(path, min, max)
+		}), true
+	case colorField:
+		colors, _ := parseColors(f.Value)
+		return anyPosting(fields, func(path string) *roaring.Bitmap {
+			return colorCandidates(p, path, f.Operator, colors)
+		}), true
+	case flagField:
+		if flag, ok := lookupFlag(f.Key, f.Value); ok {
+			return p.Term(flag.path, strconv.FormatBool(flag.value)), true
+		}
+	}
+
+	return roaring.New(), true
+}
+
+func anyPosting(fields []field, lookup func(path string) *roaring.Bitmap) *roaring.Bitmap {
+	all := make([]*roaring.Bitmap, len(fields))
+	for i, fd := range fields {
+		all[i] = lookup(fd.path)
+	}
+	return roaring.FastOr(all...)
+}
+
+// allTerms returns the documents holding every term in one of the fields.
+func allTerms(p Postings, fields []field, terms []string) *roaring.Bitmap {
+	if len(terms) == 0 {
+		return roaring.New()
+	}
+
+	return anyPosting(fields, func(path string) *roaring.Bitmap {
+		docs := p.Term(path, terms[0])
+		for _, term := range terms[1:] {
+			docs = roaring.And(docs, p.Term(path, term))
+		}
+		return docs
+	})
+}
+
+// colorCandidates follows colorQuery.
+func colorCandidates(p Postings, path, op string, colors []string) *roaring.Bitmap {
+	included := p.Every()
+	for _, c := range colors {
+		included = roaring.And(included, p.Term(path, c))
+	}
+
+	others := roaring.New()
+	for _, name := range colorNames {
+		if !slices.Contains(colors, name) {
+			others.Or(p.Term(path, name))
+		}
+	}
+
+	switch op {
+	case ":", ">=":
+		if len(colors) == 0 {
+			return roaring.AndNot(p.Every(), others)
+		}
+		return included
+	case "=":
+		return roaring.AndNot(included, others)
+	case ">":
+		return roaring.And(included, others)
+	case "<=":
+		return roaring.AndNot(p.Every(), others)
+	case "<":
+		if len(colors) == 0 {
+			return roaring.New()
+		}
+		return roaring.AndNot(roaring.AndNot(p.Every(), others), included)
+	}
+
+	return roaring.New()
+}
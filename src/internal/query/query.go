@@ -0,0 +1,77 @@
+package query
+
+// Node is a single element of a parsed search query.
+type Node interface {
+	node()
+}
+
+// And matches cards satisfying every child node.
+type And struct {
+	Nodes []Node
+}
+
+// Or matches cards satisfying at least one child node.
+type Or struct {
+	Nodes []Node
+}
+
+// Not matches cards that do not satisfy the child node.
+type Not struct {
+	Node Node
+}
+
+// Side matches cards where a single side satisfies the whole child node.
+// Card-level filters (name, set, creator, ...) inside a Side group are still
+// checked against the card.
+type Side struct {
+	Node Node
+}
+
+// Filter is a single key/operator/value term, e.g. `pow>=3`.
+// Key is always the canonical token name, never an alias. Quoted values are
+// matched as phrases on text fields. The "!" operator is only used for exact
+// name matches (`!"Goblin Guide"`).
+type Filter struct {
+	Key      string
+	Operator string
+	Value    string
+	Quoted   bool
+}
+
+func (*And) node()    {}
+func (*Or) node()     {}
+func (*Not) node()    {}
+func (*Side) node()   {}
+func (*Filter) node() {}
+
+// SameFace wraps the whole query in a Side group, so that every filter must be
+// satisfied by the same side of a card.
+func SameFace(n Node) Node {
+	if _, ok := n.(*Side); ok {
+		return n
+	}
+	return &Side{Node: n}
+}
+
+// HasSide reports whether the query contains at least one Side group.
+func HasSide(n Node) bool {
+	switch n := n.(type) {
+	case *And:
+		for _, child := range n.Nodes {
+			if HasSide(child) {
+				return true
+			}
+		}
+	case *Or:
+		for _, child := range n.Nodes {
+			if HasSide(child) {
+				return true
+			}
+		}
+	case *Not:
+		return HasSide(n.Node)
+	case *Side:
+		return true
+	}
+	return false
+}
@@ -8,42 +8,50 @@ import (
 
 type Card struct {
 	// Identifiers
+	ID      string `json:"id"` // stable across database rebuilds, see NewID
 	Name    string `json:"name"`
 	Creator string `json:"creator"`
 	Set     string `json:"set"`
 
 	// Legality
-	Legality []string `json:"legality"`
-	Rulings  string   `json:"rulings"`
+	Legality      []string `json:"legality"`
+	Rulings       string   `json:"rulings"`
+	RulingEntries []string `json:"-"` // Rulings split into individual rulings, see /cards/{id}/rulings
 
 	// Characteristics
 	ManaValue *float64 `json:"mv"`
 	Colors    []string `json:"colors"`
-	Sides     []Side   `json:"sides"` // most cards have 1 side; some have up to 4
+	TypeLine  string   `json:"type_line"` // type lines of every side, joined by " // "
+	Keywords  []string `json:"keywords"`  // keyword abilities found on any side, e.g. "Flying", "Ward"
+	Sides     []Side   `json:"sides"`     // most cards have 1 side; some have up to 4
 
 	// Refs
-	Tags   []string `json:"tags"`
-	Tokens []Token  `json:"-"`
+	Tags     []string      `json:"tags"`
+	Tokens   []Token       `json:"-"`
+	AllParts []RelatedCard `json:"all_parts,omitempty"` // tokens and components related to this card, including itself
 
 	// Additional fields
-	ComponentOf   *string `json:"component_of"`
-	IsActualToken *bool   `json:"is_actual_token"`
-	SmallAltImage *string `json:"small_alt_image"`
+	ComponentOf   *string    `json:"component_of"`
+	IsActualToken *bool      `json:"is_actual_token"`
+	SmallAltImage *string    `json:"small_alt_image"`
+	ImageURIs     *ImageURIs `json:"image_uris,omitempty"` // front image, or the first side with one
 
 	ManaValueOriginal *string `json:"mv_original"`
 }
 
 type Side struct {
-	Cost       string   `json:"cost"`
-	Supertypes []string `json:"supertypes"`
-	CardTypes  []string `json:"card_types"`
-	Subtypes   []string `json:"subtypes"`
-	ManaValue  float64  `json:"mv"`
-	Power      *float64 `json:"power"`
-	Toughness  *float64 `json:"toughness"`
-	Loyalty    *float64 `json:"loyalty"`
-	TextBox    string   `json:"textbox"`
-	FlavorText string   `json:"flavor_text"`
+	Cost       string     `json:"cost"`
+	Supertypes []string   `json:"supertypes"`
+	CardTypes  []string   `json:"card_types"`
+	Subtypes   []string   `json:"subtypes"`
+	TypeLine   string     `json:"type_line"` // e.g. "Legendary Creature — Human Noble"
+	ManaValue  float64    `json:"mv"`
+	Power      *float64   `json:"power"`
+	Toughness  *float64   `json:"toughness"`
+	Loyalty    *float64   `json:"loyalty"`
+	TextBox    string     `json:"textbox"`
+	FlavorText string     `json:"flavor_text"`
+	ImageURIs  *ImageURIs `json:"image_uris,omitempty"`
 
 	// Original fields in case numeric parsing doesn't work
 	ManaValueOriginal *string `json:"mv_original"`
@@ -60,6 +68,95 @@ type Token struct {
 	Image     string `json:"Image"`
 }
 
+// ImageURIs points at the images of a card or of one of its sides. Small is
+// only known for the card as a whole.
+type ImageURIs struct {
+	Normal string `json:"normal"`
+	Small  string `json:"small,omitempty"`
+}
+
+// Rewrite returns a copy with every URL passed through fn.
+func (u *ImageURIs) Rewrite(fn func(string) string) *ImageURIs {
+	if u == nil {
+		return nil
+	}
+
+	rewritten := ImageURIs{Normal: fn(u.Normal)}
+	if u.Small != "" {
+		rewritten.Small = fn(u.Small)
+	}
+
+	return &rewritten
+}
+
+// HasImage reports whether the card or any of its sides has an image.
+func (c *Card) HasImage() bool {
+	if c.ImageURIs != nil {
+		return true
+	}
+	for _, s := range c.Sides {
+		if s.ImageURIs != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Database is everything gendb produces for the API: the cards, including a
+// card for every token, and the token catalogue.
+type Database struct {
+	Cards  []Card
+	Tokens []CatalogToken
+}
+
+// CatalogToken is a distinct token created by at least one card. ID is the ID
+// of the card representing the token. CreatedBy lists the cards creating it.
+type CatalogToken struct {
+	Object    string        `json:"object"`
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	TypeLine  string        `json:"type_line"`
+	Power     string        `json:"power"`
+	Toughness string        `json:"toughness"`
+	Image     string        `json:"image,omitempty"`
+	CreatedBy []RelatedCard `json:"created_by"`
+}
+
+// RelatedCard links a card to a token it creates or to the other components
+// of the same card, shaped after Scryfall's related card object. ID is a card
+// ID, or a token ID when the token has no card of its own.
+type RelatedCard struct {
+	Object    string `json:"object"`
+	ID        string `json:"id"`
+	Component string `json:"component"`
+	Name      string `json:"name"`
+	TypeLine  string `json:"type_line"`
+}
+
+const (
+	ComponentToken      = "token"
+	ComponentComboPiece = "combo_piece"
+)
+
+const TypeLineSeparator = " — "
+const FaceSeparator = " // "
+
+// BuildTypeLine combines the type lists of a side into a single line,
+// e.g. "Legendary Creature — Human Noble".
+func BuildTypeLine(supertypes, cardTypes, subtypes []string) string {
+	types := strings.Join(append(append([]string{}, supertypes...), cardTypes...), " ")
+
+	if len(subtypes) == 0 {
+		return types
+	}
+
+	if types == "" {
+		return strings.Join(subtypes, " ")
+	}
+
+	return types + TypeLineSeparator + strings.Join(subtypes, " ")
+}
+
 var ManaTokensWihoutIdentity = map[string]struct{}{
 	"H": {},
 	"X": {},
@@ -0,0 +1,43 @@
+package cards
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+)
+
+// Namespaces keep card and token IDs apart when they share a name.
+const (
+	cardNamespace  = "card"
+	tokenNamespace = "token"
+)
+
+// NewID derives a UUID-shaped identifier from a card name, so IDs survive
+// database rebuilds as long as the card keeps its name.
+func NewID(name string) string {
+	return hashID(cardNamespace, name)
+}
+
+// TokenID derives the identifier of a token that has no card of its own from
+// its characteristics. Identical tokens created by different cards share it.
+func TokenID(t Token) string {
+	return hashID(tokenNamespace, t.Name, t.Type, t.Power, t.Toughness)
+}
+
+func hashID(namespace string, parts ...string) string {
+	h := sha1.New()
+	h.Write([]byte(namespace))
+
+	for _, part := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(strings.ToLower(strings.TrimSpace(part))))
+	}
+
+	sum := h.Sum(nil)
+
+	// Version 5 style (name-based, SHA-1) UUID
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,192 @@
+package hellfall
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Keyword abilities recognised in rules text, spelled the way they are
+// reported. Hellscube-specific keywords are added with NewKeywordExtractor.
+var knownKeywords = []string{
+	// Evergreen
+	"Deathtouch", "Defender", "Double strike", "Enchant", "Equip", "First strike", "Flash", "Flying",
+	"Haste", "Hexproof", "Indestructible", "Lifelink", "Menace", "Protection", "Reach", "Trample",
+	"Vigilance", "Ward",
+
+	// Set and retired keywords
+	"Absorb", "Affinity", "Afflict", "Afterlife", "Aftermath", "Amplify", "Annihilator", "Ascend",
+	"Assist", "Aura swap", "Awaken", "Backup", "Banding", "Bargain", "Battle cry", "Bestow", "Blitz",
+	"Bloodthirst", "Bushido", "Buyback", "Cascade", "Casualty", "Champion", "Changeling", "Cipher",
+	"Cleave", "Companion", "Conspire", "Convoke", "Craft", "Crew", "Cumulative upkeep", "Cycling",
+	"Dash", "Daybound", "Decayed", "Delve", "Dethrone", "Devoid", "Devour", "Disguise", "Disturb",
+	"Dredge", "Echo", "Embalm", "Emerge", "Enlist", "Entwine", "Epic", "Escape", "Eternalize",
+	"Evoke", "Evolve", "Exalted", "Exploit", "Extort", "Fabricate", "Fading", "Fear", "Flanking",
+	"Flashback", "Forecast", "Foretell", "Frenzy", "Fuse", "Graft", "Gravestorm", "Haunt",
+	"Hidden agenda", "Horsemanship", "Impending", "Improvise", "Infect", "Ingest", "Intimidate",
+	"Jump-start", "Kicker", "Landwalk", "Forestwalk", "Islandwalk", "Mountainwalk", "Plainswalk",
+	"Swampwalk", "Level up", "Living metal", "Living weapon", "Madness", "Megamorph", "Melee",
+	"Mentor", "Miracle", "Modular", "Morph", "Multikicker", "Mutate", "Myriad", "Nightbound",
+	"Ninjutsu", "Offspring", "Outlast", "Overload", "Partner", "Persist", "Phasing", "Plot",
+	"Poisonous", "Prototype", "Provoke", "Prowess", "Prowl", "Rampage", "Ravenous", "Read ahead",
+	"Rebound", "Reconfigure", "Recover", "Renown", "Replicate", "Retrace", "Riot", "Scavenge",
+	"Shadow", "Shroud", "Skulk", "Soulbond", "Soulshift", "Spectacle", "Splice", "Split second",
+	"Squad", "Storm", "Sunburst", "Surge", "Suspend", "Toxic", "Training", "Transfigure",
+	"Transmute", "Tribute", "Umbra armor", "Totem armor", "Undaunted", "Undying", "Unearth",
+	"Unleash", "Vanishing", "Wither",
+}
+
+// objectKeywords take an object instead of a number or a cost ("Enchant
+// creature", "Affinity for artifacts"), introduced by the given word, or
+// following the keyword directly when it is empty.
+var objectKeywords = map[string]string{
+	"enchant":  "",
+	"affinity": "for ",
+	"partner":  "with ",
+	"champion": "",
+	"splice":   "onto ",
+}
+
+// landwalk matches the landwalk abilities that aren't keywords of their own,
+// e.g. "Desertwalk", "Nonbasic landwalk" or "Snow forestwalk".
+var landwalk = regexp.MustCompile(`(?i)^[a-z]+(?: [a-z]+){0,2}walk$`)
+
+// reminderText matches parenthesised reminder text, e.g. "(This creature can't be blocked...)".
+var reminderText = regexp.MustCompile(`\([^)]*\)`)
+
+// KeywordExtractor finds keyword abilities in rules text. Only lines made
+// entirely of keywords count ("Flying, trample", "Ward 2", "Kicker {2}{R}"),
+// so "Creatures you control have flying" does not give a card flying.
+type KeywordExtractor struct {
+	keywords []string // longest first, so "First strike" wins over "First"
+}
+
+// NewKeywordExtractor returns an extractor recognising the known keywords
+// plus any custom (Hellscube-invented) ones.
+func NewKeywordExtractor(custom []string) *KeywordExtractor {
+	keywords := normaliseList(append(append([]string{}, knownKeywords...), custom...), nil)
+
+	sort.SliceStable(keywords, func(i, j int) bool {
+		return len(keywords[i]) > len(keywords[j])
+	})
+
+	return &KeywordExtractor{keywords: keywords}
+}
+
+// ReadKeywordList reads custom keywords, one per line. Blank lines and lines
+// starting with "#" are ignored.
+func ReadKeywordList(r io.Reader) ([]string, error) {
+	keywords := []string{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keywords = append(keywords, line)
+	}
+
+	return keywords, scanner.Err()
+}
+
+// Extract returns the keywords found in text, in order of appearance and
+// without their parameters ("Ward 2" is reported as "Ward").
+func (e *KeywordExtractor) Extract(text string) []string {
+	found := []string{}
+
+	for _, line := range strings.Split(reminderText.ReplaceAllString(text, ""), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		lineKeywords := []string{}
+
+		for _, segment := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ';' }) {
+			keyword, ok := e.match(strings.TrimSpace(segment))
+			if !ok {
+				lineKeywords = nil
+				break
+			}
+			lineKeywords = append(lineKeywords, keyword)
+		}
+
+		found = append(found, lineKeywords...)
+	}
+
+	return normaliseList(found, nil)
+}
+
+// match checks whether a segment is a keyword, optionally followed by its
+// parameter: a number or X, a cost, an em dash cost, a "from" clause, or the
+// object of the keywords taking one. Landwalk abilities are reported as
+// Landwalk.
+func (e *KeywordExtractor) match(segment string) (string, bool) {
+	for _, keyword := range e.keywords {
+		if len(segment) < len(keyword) || !strings.EqualFold(segment[:len(keyword)], keyword) {
+			continue
+		}
+
+		rest := strings.TrimSpace(segment[len(keyword):])
+		if rest == "" || isKeywordParameter(rest) || isKeywordObject(keyword, segment[len(keyword):]) {
+			return keyword, true
+		}
+	}
+
+	if landwalk.MatchString(segment) {
+		return "Landwalk", true
+	}
+
+	return "", false
+}
+
+func isKeywordParameter(rest string) bool {
+	first := []rune(rest)[0]
+
+	switch {
+	case unicode.IsDigit(first), first == 'X', first == '{', first == '—', first == '–', first == '-':
+		return true
+	case strings.HasPrefix(strings.ToLower(rest), "from "):
+		return true
+	}
+
+	return false
+}
+
+// isKeywordObject reports whether rest, what follows keyword in a segment,
+// is its object: words after the word introducing them, then maybe a cost.
+// rest must start with a space, so "Enchanted creature" isn't read as
+// Enchant.
+func isKeywordObject(keyword, rest string) bool {
+	intro, ok := objectKeywords[strings.ToLower(keyword)]
+	if !ok || !strings.HasPrefix(rest, " ") {
+		return false
+	}
+
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(strings.ToLower(rest), intro) {
+		return false
+	}
+
+	// A cost may follow, as in "Splice onto Arcane {1}{R}"
+	object := rest[len(intro):]
+	if i := strings.IndexAny(object, "{—–"); i >= 0 {
+		object = strings.TrimSpace(object[:i])
+	}
+
+	if object == "" {
+		return false
+	}
+
+	for _, r := range object {
+		if !unicode.IsLetter(r) && r != ' ' && r != '-' && r != '\'' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,49 @@
+package hellfall
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractKeywords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Flying, trample", []string{"Flying", "Trample"}},
+		{"Ward 2", []string{"Ward"}},
+		{"Kicker {2}{R}", []string{"Kicker"}},
+
+		// Keywords taking an object
+		{"Enchant creature", []string{"Enchant"}},
+		{"Enchant creature or planeswalker", []string{"Enchant"}},
+		{"Affinity for artifacts", []string{"Affinity"}},
+		{"Partner with Foo", []string{"Partner"}},
+		{"Partner", []string{"Partner"}},
+		{"Champion a Faerie", []string{"Champion"}},
+		{"Splice onto Arcane {1}{R}", []string{"Splice"}},
+		{"Splice onto Arcane", []string{"Splice"}},
+		{"Flying\nEnchant player", []string{"Flying", "Enchant"}},
+
+		// Landwalk
+		{"Swampwalk", []string{"Swampwalk"}},
+		{"Nonbasic landwalk", []string{"Landwalk"}},
+		{"Desertwalk, haste", []string{"Landwalk", "Haste"}},
+
+		// Not keyword lines
+		{"Enchanted creature has flying", []string{}},
+		{"Affinity artifacts", []string{}},
+		{"Partner of Foo", []string{}},
+		{"Creatures you control have flying", []string{}},
+	}
+
+	extractor := NewKeywordExtractor(nil)
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := extractor.Extract(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Extract(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package hellfall
+
+import "strings"
+
+// Vocabularies map lowercased values to their canonical spelling, so that
+// "red", "Red " and "RED" in the sheet all end up as "Red".
+var colorVocabulary = vocabulary("White", "Blue", "Black", "Red", "Green", "Purple")
+var legalityVocabulary = vocabulary("Legal", "Banned", "Restricted", "Not Legal")
+var supertypeVocabulary = vocabulary("Basic", "Legendary", "Ongoing", "Snow", "World", "Elite", "Host")
+var cardTypeVocabulary = vocabulary(
+	"Artifact", "Battle", "Conspiracy", "Creature", "Dungeon", "Enchantment", "Instant", "Kindred",
+	"Land", "Phenomenon", "Plane", "Planeswalker", "Scheme", "Sorcery", "Tribal", "Vanguard", "Token",
+)
+
+func vocabulary(values ...string) map[string]string {
+	vocab := make(map[string]string, len(values))
+	for _, v := range values {
+		vocab[strings.ToLower(v)] = v
+	}
+	return vocab
+}
+
+// splitList splits a ";" separated cell and normalises the values.
+// strings.Split("", ";") returns [""], which this turns into an empty list.
+func splitList(s string, vocab map[string]string) []string {
+	return normaliseList(strings.Split(s, sep), vocab)
+}
+
+// normaliseList trims values and drops empty ones and duplicates (ignoring
+// case, first spelling wins). Values found in vocab take their canonical
+// spelling; vocab may be nil.
+func normaliseList(values []string, vocab map[string]string) []string {
+	result := []string{}
+	seen := map[string]struct{}{}
+
+	for _, v := range values {
+		v = strings.Join(strings.Fields(v), " ")
+		if v == "" {
+			continue
+		}
+
+		key := strings.ToLower(v)
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		if canonical, ok := vocab[key]; ok {
+			v = canonical
+		}
+
+		result = append(result, v)
+	}
+
+	return result
+}
@@ -0,0 +1,54 @@
+package hellfall
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name  string
+		cell  string
+		vocab map[string]string
+		want  []string
+	}{
+		{"empty cell", "", colorVocabulary, []string{}},
+		{"only a separator", ";", colorVocabulary, []string{}},
+		{"padding, case and trailing separator", " Red ; red;", colorVocabulary, []string{"Red"}},
+		{"duplicate in other case", "legendary;LEGENDARY", supertypeVocabulary, []string{"Legendary"}},
+		{"several values", "white; BLUE ;Red", colorVocabulary, []string{"White", "Blue", "Red"}},
+		{"inner whitespace", "not   legal; Not Legal", legalityVocabulary, []string{"Not Legal"}},
+		{"tabs and newlines", "Artifact\t;\n creature ", cardTypeVocabulary, []string{"Artifact", "Creature"}},
+		{"unknown values keep their spelling", "goblin  WIZARD;Goblin wizard", nil, []string{"goblin WIZARD"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitList(tt.cell, tt.vocab); !slices.Equal(got, tt.want) {
+				t.Errorf("splitList(%q) = %q, want %q", tt.cell, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormaliseList(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		vocab  map[string]string
+		want   []string
+	}{
+		{"nil", nil, legalityVocabulary, []string{}},
+		{"blank values", []string{"", "  ", "\t"}, legalityVocabulary, []string{}},
+		{"canonical case", []string{" banned", "LEGAL "}, legalityVocabulary, []string{"Banned", "Legal"}},
+		{"first spelling wins without vocabulary", []string{"Sol Ring", "sol  ring"}, nil, []string{"Sol Ring"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normaliseList(tt.values, tt.vocab); !slices.Equal(got, tt.want) {
+				t.Errorf("normaliseList(%q) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
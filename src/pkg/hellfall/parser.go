@@ -10,25 +10,36 @@ import (
 const max_sides = 4 // Current maximum number of sides for any card
 const sep = ";"
 
-func NormaliseDB(db *Root) []cards.Card {
+// NormaliseDB converts the sheet export into cards. keywords finds keyword
+// abilities in rules text; nil uses the built-in keyword list.
+func NormaliseDB(db *Root, keywords *KeywordExtractor) []cards.Card {
 	result := []cards.Card{}
 
+	if keywords == nil {
+		keywords = NewKeywordExtractor(nil)
+	}
+
 	for _, c := range db.Data {
 		manaValueStr, manaValueNum := getStringAndNumber(c.CMC)
+		sides := ParseSides(c)
 		card := cards.Card{
-			Name:              c.Name,
-			Creator:           c.Creator,
-			Set:               c.Set,
-			Legality:          c.ConstructedLegality,
+			Name:              strings.TrimSpace(c.Name),
+			Creator:           strings.TrimSpace(c.Creator),
+			Set:               strings.TrimSpace(c.Set),
+			Legality:          normaliseList(c.ConstructedLegality, legalityVocabulary),
 			Rulings:           c.Rulings,
+			RulingEntries:     ParseRulings(c.Rulings),
 			ManaValue:         manaValueNum,
-			Colors:            strings.Split(c.Colors, sep),
-			Sides:             ParseSides(c),
-			Tags:              strings.Split(c.Tags, sep),
+			Colors:            splitList(c.Colors, colorVocabulary),
+			TypeLine:          joinTypeLines(sides),
+			Keywords:          extractKeywords(keywords, sides),
+			Sides:             sides,
+			Tags:              splitList(c.Tags, nil),
 			Tokens:            toDomainTokens(c.Tokens),
 			ComponentOf:       c.ComponentOf,
 			IsActualToken:     c.IsActualToken,
 			SmallAltImage:     c.SmallAltImage,
+			ImageURIs:         cardImageURIs(c),
 			ManaValueOriginal: manaValueStr,
 		}
 		result = append(result, card)
@@ -76,6 +87,10 @@ func ParseSides(c CardEntry) []cards.Side {
 		c.FlavorText = []*string{nil, nil, nil, nil}
 	}
 
+	for len(c.Image) < max_sides {
+		c.Image = append(c.Image, nil)
+	}
+
 	for i := range max_sides {
 		if (c.Cost[i] == nil || *c.Cost[i] == "") &&
 			(c.Supertypes[i] == nil || *c.Supertypes[i] == "") &&
@@ -96,11 +111,16 @@ func ParseSides(c CardEntry) []cards.Side {
 		toughnessStr, toughnessNum := getStringAndNumber(c.Toughness[i])
 		loyaltyStr, loyaltyNum := getStringAndNumber(c.Loyalty[i])
 
+		supertypes := splitList(utils.Coalesce(c.Supertypes[i], ""), supertypeVocabulary)
+		cardTypes := splitList(utils.Coalesce(c.CardTypes[i], ""), cardTypeVocabulary)
+		subtypes := splitList(utils.Coalesce(c.Subtypes[i], ""), nil)
+
 		side := cards.Side{
 			Cost:       utils.Coalesce(c.Cost[i], ""),
-			Supertypes: strings.Split(utils.Coalesce(c.Supertypes[i], ""), sep),
-			CardTypes:  strings.Split(utils.Coalesce(c.CardTypes[i], ""), sep),
-			Subtypes:   strings.Split(utils.Coalesce(c.Subtypes[i], ""), sep),
+			Supertypes: supertypes,
+			CardTypes:  cardTypes,
+			Subtypes:   subtypes,
+			TypeLine:   cards.BuildTypeLine(supertypes, cardTypes, subtypes),
 
 			Power:             powerNum,
 			Toughness:         toughnessNum,
@@ -111,6 +131,7 @@ func ParseSides(c CardEntry) []cards.Side {
 			LoyaltyOriginal:   loyaltyStr,
 			TextBox:           utils.Coalesce(c.TextBox[i], ""),
 			FlavorText:        utils.Coalesce(c.FlavorText[i], ""),
+			ImageURIs:         imageURIs(c.Image[i]),
 		}
 
 		sides = append(sides, side)
@@ -119,6 +140,55 @@ func ParseSides(c CardEntry) []cards.Side {
 	return sides
 }
 
+// cardImageURIs picks the first image of the card, the front one unless it
+// is missing.
+func cardImageURIs(c CardEntry) *cards.ImageURIs {
+	for _, image := range c.Image {
+		uris := imageURIs(image)
+		if uris == nil {
+			continue
+		}
+
+		if c.SmallAltImage != nil {
+			uris.Small = strings.TrimSpace(*c.SmallAltImage)
+		}
+
+		return uris
+	}
+
+	return nil
+}
+
+func imageURIs(image *string) *cards.ImageURIs {
+	url := strings.TrimSpace(utils.Coalesce(image, ""))
+	if url == "" {
+		return nil
+	}
+	return &cards.ImageURIs{Normal: url}
+}
+
+func joinTypeLines(sides []cards.Side) string {
+	lines := []string{}
+
+	for _, side := range sides {
+		if side.TypeLine != "" {
+			lines = append(lines, side.TypeLine)
+		}
+	}
+
+	return strings.Join(lines, cards.FaceSeparator)
+}
+
+func extractKeywords(extractor *KeywordExtractor, sides []cards.Side) []string {
+	keywords := []string{}
+
+	for _, side := range sides {
+		keywords = append(keywords, extractor.Extract(side.TextBox)...)
+	}
+
+	return normaliseList(keywords, nil)
+}
+
 func getStringAndNumber(field *any) (*string, *float64) {
 	var fieldStr string
 	var fieldFloat *float64
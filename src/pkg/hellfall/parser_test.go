@@ -0,0 +1,161 @@
+package hellfall
+
+import (
+	"hf-api/src/internal/query"
+	"hf-api/src/pkg/cards"
+	"slices"
+	"testing"
+)
+
+// cells returns a side column of the sheet, which always has max_sides
+// cells, "" leaving a side blank.
+func cells(values ...string) []*string {
+	column := make([]*string, max_sides)
+	for i := range values {
+		if values[i] != "" {
+			column[i] = &values[i]
+		}
+	}
+	return column
+}
+
+func TestBuildTypeLine(t *testing.T) {
+	tests := []struct {
+		name                            string
+		supertypes, cardTypes, subtypes []string
+		want                            string
+	}{
+		{"card types only", nil, []string{"Instant"}, nil, "Instant"},
+		{"subtypes", nil, []string{"Creature"}, []string{"Goblin", "Scout"}, "Creature — Goblin Scout"},
+		{"supertypes", []string{"Legendary", "Snow"}, []string{"Artifact", "Creature"}, []string{"Golem"}, "Legendary Snow Artifact Creature — Golem"},
+		{"supertypes only", []string{"Legendary"}, []string{"Planeswalker"}, nil, "Legendary Planeswalker"},
+		{"subtypes only", nil, nil, []string{"Goblin"}, "Goblin"},
+		{"nothing", nil, nil, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cards.BuildTypeLine(tt.supertypes, tt.cardTypes, tt.subtypes); got != tt.want {
+				t.Errorf("BuildTypeLine(%q, %q, %q) = %q, want %q", tt.supertypes, tt.cardTypes, tt.subtypes, got, tt.want)
+			}
+		})
+	}
+
+	// The lists are not changed by building the line
+	supertypes := make([]string, 1, 4)
+	supertypes[0] = "Legendary"
+	cards.BuildTypeLine(supertypes, []string{"Creature"}, nil)
+
+	if extended := supertypes[:2]; extended[1] != "" {
+		t.Errorf("BuildTypeLine wrote %q past the supertypes", extended[1])
+	}
+}
+
+func TestParseSidesTypes(t *testing.T) {
+	entry := CardEntry{
+		Name:       "Legendary Goblin Boss // Goblin Boss's Wagon",
+		Supertypes: cells("legendary", ""),
+		CardTypes:  cells("creature", "Artifact"),
+		Subtypes:   cells("Goblin; Noble", "Vehicle"),
+		TextBox:    cells("Other Goblins you control get +1/+1.", "Crew 1"),
+	}
+
+	card := NormaliseDB(&Root{Data: []CardEntry{entry}}, nil)[0]
+
+	if len(card.Sides) != 2 {
+		t.Fatalf("got %d sides, want 2", len(card.Sides))
+	}
+
+	boss, wagon := card.Sides[0], card.Sides[1]
+
+	if !slices.Equal(boss.Supertypes, []string{"Legendary"}) || !slices.Equal(boss.CardTypes, []string{"Creature"}) ||
+		!slices.Equal(boss.Subtypes, []string{"Goblin", "Noble"}) {
+		t.Errorf("first side has supertypes %q, card types %q and subtypes %q", boss.Supertypes, boss.CardTypes, boss.Subtypes)
+	}
+
+	if len(wagon.Supertypes) != 0 || !slices.Equal(wagon.Subtypes, []string{"Vehicle"}) {
+		t.Errorf("second side has supertypes %q and subtypes %q", wagon.Supertypes, wagon.Subtypes)
+	}
+
+	if want := "Legendary Creature — Goblin Noble"; boss.TypeLine != want {
+		t.Errorf("first side's type line is %q, want %q", boss.TypeLine, want)
+	}
+
+	if want := "Legendary Creature — Goblin Noble // Artifact — Vehicle"; card.TypeLine != want {
+		t.Errorf("card's type line is %q, want %q", card.TypeLine, want)
+	}
+}
+
+// Searches of the type line go through the supertype/subtype split above, so
+// the words of `t:` can come in any order.
+func TestTypeLineSearch(t *testing.T) {
+	entry := CardEntry{
+		Name:       "Goblin Chieftain",
+		Supertypes: cells("Legendary"),
+		CardTypes:  cells("Creature"),
+		Subtypes:   cells("Goblin"),
+	}
+
+	card := NormaliseDB(&Root{Data: []CardEntry{entry}}, nil)[0]
+
+	if want := "Legendary Creature — Goblin"; card.TypeLine != want {
+		t.Fatalf("type line is %q, want %q", card.TypeLine, want)
+	}
+
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{`t:"legendary goblin"`, true},
+		{`t:"goblin legendary"`, true},
+		{`t:"Creature Legendary"`, true},
+		{`t:"legendary creature goblin"`, true},
+		{"t:legendary t:goblin", true},
+		{`t:"legendary elf"`, false},
+		{`t:"snow goblin"`, false},
+		{`t:"legendary goblin" -t:noble`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			root, issues, err := query.Parse(tt.query)
+			if err != nil || len(issues) > 0 {
+				t.Fatalf("Parse(%q) failed: %v %+v", tt.query, err, issues)
+			}
+
+			if ok, _ := query.Match(root, &card); ok != tt.ok {
+				t.Errorf("Match(%q) = %v, want %v", tt.query, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCardImageURIs(t *testing.T) {
+	small := " https://img.example.com/small.png "
+
+	tests := []struct {
+		name  string
+		entry CardEntry
+		want  *cards.ImageURIs
+	}{
+		{"no image", CardEntry{Image: cells("", "")}, nil},
+		{"front", CardEntry{Image: cells("https://img.example.com/front.png")}, &cards.ImageURIs{Normal: "https://img.example.com/front.png"}},
+		{"first side with one", CardEntry{Image: cells(" ", " https://img.example.com/back.png")}, &cards.ImageURIs{Normal: "https://img.example.com/back.png"}},
+		{
+			"small alt image",
+			CardEntry{Image: cells("https://img.example.com/front.png"), SmallAltImage: &small},
+			&cards.ImageURIs{Normal: "https://img.example.com/front.png", Small: "https://img.example.com/small.png"},
+		},
+		{"small alt image alone", CardEntry{Image: cells(""), SmallAltImage: &small}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cardImageURIs(tt.entry)
+
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("cardImageURIs = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
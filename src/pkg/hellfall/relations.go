@@ -0,0 +1,158 @@
+package hellfall
+
+import (
+	"fmt"
+	"hf-api/src/pkg/cards"
+	"strconv"
+	"strings"
+)
+
+// AssignIDs gives every card its stable ID. Cards sharing a name keep the
+// first one; the others get an ID derived from their position among the
+// duplicates, so the sheet order decides which is which.
+func AssignIDs(db []cards.Card) {
+	seen := map[string]int{}
+
+	for i := range db {
+		key := strings.ToLower(db[i].Name)
+		n := seen[key]
+		seen[key] = n + 1
+
+		if n == 0 {
+			db[i].ID = cards.NewID(db[i].Name)
+		} else {
+			db[i].ID = cards.NewID(db[i].Name + "#" + strconv.Itoa(n))
+		}
+	}
+}
+
+// ResolveParts fills in AllParts from the tokens each card creates and from
+// ComponentOf. Tokens are linked to the card representing them when there is
+// one (IsActualToken), and that card links back to its creators. Every card
+// sharing a ComponentOf, and the card it names, are linked to each other.
+// Returns a warning for every reference that couldn't be resolved.
+func ResolveParts(db []cards.Card) []string {
+	warnings := []string{}
+
+	byName := map[string]int{}
+	tokenCards := indexTokenCards(db)
+
+	for i, c := range db {
+		key := strings.ToLower(c.Name)
+		if _, ok := byName[key]; !ok {
+			byName[key] = i
+		}
+	}
+
+	parts := make([][]cards.RelatedCard, len(db))
+
+	// Tokens
+	for i, c := range db {
+		for _, t := range c.Tokens {
+			if j, ok := tokenCards.find(t); ok {
+				parts[i] = addPart(parts[i], cardPart(&db[j], cards.ComponentToken))
+				parts[j] = addPart(parts[j], cardPart(&db[i], cards.ComponentComboPiece))
+				continue
+			}
+
+			parts[i] = addPart(parts[i], cards.RelatedCard{
+				Object:    "related_card",
+				ID:        cards.TokenID(t),
+				Component: cards.ComponentToken,
+				Name:      strings.TrimSpace(t.Name),
+				TypeLine:  strings.TrimSpace(t.Type),
+			})
+		}
+	}
+
+	// Components, grouped by the card they belong to
+	groups := map[int][]int{}
+
+	for i, c := range db {
+		if c.ComponentOf == nil {
+			continue
+		}
+
+		for _, name := range splitList(*c.ComponentOf, nil) {
+			j, ok := byName[strings.ToLower(name)]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("%s: unknown component of %q", c.Name, name))
+				continue
+			}
+			if j != i {
+				groups[j] = append(groups[j], i)
+			}
+		}
+	}
+
+	for whole, components := range groups {
+		members := append([]int{whole}, components...)
+
+		for _, i := range members {
+			for _, j := range members {
+				if i != j {
+					parts[i] = addPart(parts[i], cardPart(&db[j], cards.ComponentComboPiece))
+				}
+			}
+		}
+	}
+
+	// Like Scryfall, a card with related parts lists itself too
+	for i := range db {
+		if len(parts[i]) == 0 {
+			continue
+		}
+
+		self := cardPart(&db[i], cards.ComponentComboPiece)
+		if isToken(&db[i]) {
+			self.Component = cards.ComponentToken
+		}
+
+		db[i].AllParts = append([]cards.RelatedCard{self}, parts[i]...)
+	}
+
+	return warnings
+}
+
+// tokenCardIndex finds the card representing a token, by name.
+type tokenCardIndex map[string]int
+
+func indexTokenCards(db []cards.Card) tokenCardIndex {
+	index := tokenCardIndex{}
+
+	for i := range db {
+		if isToken(&db[i]) {
+			index[strings.ToLower(db[i].Name)] = i
+		}
+	}
+
+	return index
+}
+
+func (index tokenCardIndex) find(t cards.Token) (int, bool) {
+	i, ok := index[strings.ToLower(strings.TrimSpace(t.Name))]
+	return i, ok
+}
+
+func isToken(c *cards.Card) bool {
+	return c.IsActualToken != nil && *c.IsActualToken
+}
+
+func cardPart(c *cards.Card, component string) cards.RelatedCard {
+	return cards.RelatedCard{
+		Object:    "related_card",
+		ID:        c.ID,
+		Component: component,
+		Name:      c.Name,
+		TypeLine:  c.TypeLine,
+	}
+}
+
+func addPart(parts []cards.RelatedCard, part cards.RelatedCard) []cards.RelatedCard {
+	for _, p := range parts {
+		if p.ID == part.ID {
+			return parts
+		}
+	}
+	return append(parts, part)
+}
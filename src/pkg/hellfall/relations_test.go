@@ -0,0 +1,123 @@
+package hellfall
+
+import (
+	"hf-api/src/pkg/cards"
+	"slices"
+	"testing"
+)
+
+func ids(db []cards.Card) []string {
+	ids := make([]string, len(db))
+	for i := range db {
+		ids[i] = db[i].ID
+	}
+	return ids
+}
+
+func TestAssignIDsAreStable(t *testing.T) {
+	db := []cards.Card{{Name: "Goblin Guide"}, {Name: "Elf Warrior"}, {Name: "goblin guide"}, {Name: "Goblin Guide"}}
+	AssignIDs(db)
+	first := ids(db)
+
+	if first[0] != cards.NewID("Goblin Guide") {
+		t.Errorf("the first Goblin Guide has ID %s, want NewID of its name", first[0])
+	}
+
+	if len(slices.Compact(slices.Sorted(slices.Values(first)))) != len(first) {
+		t.Errorf("duplicate names share IDs: %v", first)
+	}
+
+	// A rebuild of the same sheet, with other cards added around them, keeps
+	// every ID
+	rebuilt := []cards.Card{{Name: "Colossal Dreadmaw"}, {Name: "Goblin Guide"}, {Name: "Elf Warrior"}, {Name: "goblin guide"}, {Name: "Goblin Guide"}}
+	AssignIDs(rebuilt)
+
+	if got := ids(rebuilt)[1:]; !slices.Equal(got, first) {
+		t.Errorf("rebuilt IDs are %v, want %v", got, first)
+	}
+}
+
+// relationsDB has a card creating a token that has a card of its own, one
+// creating a token that hasn't, and a card with two components.
+func relationsDB() []cards.Card {
+	isToken := true
+	whole := "Voltron"
+
+	db := []cards.Card{
+		{Name: "Goblin Maker", Tokens: []cards.Token{{Name: "Goblin", Type: "Token Creature — Goblin"}, {Name: "Treasure", Type: "Token Artifact — Treasure"}}},
+		{Name: "Goblin", TypeLine: "Token Creature — Goblin", IsActualToken: &isToken},
+		{Name: "Voltron", TypeLine: "Legendary Creature — Robot"},
+		{Name: "Left Arm", ComponentOf: &whole},
+		{Name: "Right Arm", ComponentOf: &whole},
+		{Name: "Elf Warrior"},
+	}
+
+	AssignIDs(db)
+	return db
+}
+
+func partIDs(c *cards.Card) []string {
+	ids := []string{}
+	for _, part := range c.AllParts {
+		ids = append(ids, part.ID)
+	}
+	return ids
+}
+
+func TestResolveParts(t *testing.T) {
+	db := relationsDB()
+
+	if warnings := ResolveParts(db); len(warnings) > 0 {
+		t.Fatalf("ResolveParts warned %v", warnings)
+	}
+
+	maker, goblin, voltron, left, right, elf := &db[0], &db[1], &db[2], &db[3], &db[4], &db[5]
+	treasure := cards.TokenID(maker.Tokens[1])
+
+	tests := []struct {
+		card *cards.Card
+		want []string
+	}{
+		{maker, []string{maker.ID, goblin.ID, treasure}},
+		{goblin, []string{goblin.ID, maker.ID}},
+		{voltron, []string{voltron.ID, left.ID, right.ID}},
+		{left, []string{left.ID, voltron.ID, right.ID}},
+		{right, []string{right.ID, voltron.ID, left.ID}},
+		{elf, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.card.Name, func(t *testing.T) {
+			got := partIDs(tt.card)
+
+			// Like Scryfall, the card comes first and only once
+			if len(got) > 0 && (got[0] != tt.card.ID || slices.Contains(got[1:], tt.card.ID)) {
+				t.Errorf("all_parts of %s lists itself other than first: %v", tt.card.Name, got)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("all_parts of %s = %v, want %v", tt.card.Name, got, tt.want)
+			}
+		})
+	}
+
+	if goblin.AllParts[0].Component != cards.ComponentToken || maker.AllParts[1].Component != cards.ComponentToken {
+		t.Errorf("the goblin token isn't listed as a token: %+v, %+v", goblin.AllParts[0], maker.AllParts[1])
+	}
+}
+
+func TestResolvePartsWarnings(t *testing.T) {
+	missing := "Nothing"
+	db := []cards.Card{{Name: "Lost Arm", ComponentOf: &missing}}
+	AssignIDs(db)
+
+	warnings := ResolveParts(db)
+
+	if want := []string{`Lost Arm: unknown component of "Nothing"`}; !slices.Equal(warnings, want) {
+		t.Errorf("ResolveParts warned %q, want %q", warnings, want)
+	}
+
+	if len(db[0].AllParts) != 0 {
+		t.Errorf("a card without parts has all_parts %v", partIDs(&db[0]))
+	}
+}
@@ -0,0 +1,31 @@
+package hellfall
+
+import "strings"
+
+// Bullets starting a ruling. "•" also separates rulings written on one line.
+var rulingBullets = []string{"-", "*", "·", "–", "—"}
+
+// ParseRulings splits the free-text Rulings cell into individual rulings, one
+// per line or bullet, without the bullets themselves.
+func ParseRulings(text string) []string {
+	rulings := []string{}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		for _, ruling := range strings.Split(line, "•") {
+			ruling = strings.TrimSpace(ruling)
+
+			for _, bullet := range rulingBullets {
+				if strings.HasPrefix(ruling, bullet+" ") {
+					ruling = strings.TrimSpace(ruling[len(bullet):])
+					break
+				}
+			}
+
+			if ruling != "" {
+				rulings = append(rulings, ruling)
+			}
+		}
+	}
+
+	return rulings
+}
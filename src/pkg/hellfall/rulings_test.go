@@ -0,0 +1,37 @@
+package hellfall
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRulings(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", []string{}},
+		{"blank lines", "\n  \r\n", []string{}},
+		{"single ruling", "It counts itself.", []string{"It counts itself."}},
+		{"one per line", "It counts itself.\nIt can't be countered.", []string{"It counts itself.", "It can't be countered."}},
+		{"windows line endings", "It counts itself.\r\nIt can't be countered.\r\n", []string{"It counts itself.", "It can't be countered."}},
+		{"bullets on one line", "• It counts itself. • It can't be countered.", []string{"It counts itself.", "It can't be countered."}},
+		{"bullets and lines", "• It counts itself.\n• It can't be countered. • Copies too.", []string{"It counts itself.", "It can't be countered.", "Copies too."}},
+		{"dash bullets", "- It counts itself.\n* It can't be countered.\n– Copies too.\n— Tokens too.\n· Emblems too.", []string{"It counts itself.", "It can't be countered.", "Copies too.", "Tokens too.", "Emblems too."}},
+		{"indented bullets", "  -   It counts itself.", []string{"It counts itself."}},
+
+		// Dashes only start a bullet when followed by a space
+		{"negative numbers", "-1/-1 counters are removed first.", []string{"-1/-1 counters are removed first."}},
+		{"dashes inside", "It gets +1/+1 - even as a copy.", []string{"It gets +1/+1 - even as a copy."}},
+		{"only one bullet removed", "- - It counts itself.", []string{"- It counts itself."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRulings(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseRulings(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
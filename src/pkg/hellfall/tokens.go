@@ -0,0 +1,145 @@
+package hellfall
+
+import (
+	"hf-api/src/pkg/cards"
+	"hf-api/src/utils"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BuildTokens makes tokens first-class cards and builds the token catalogue.
+// Tokens created by cards but without a card of their own (IsActualToken) get
+// one, appended to db with the ID already used for them in AllParts, so they
+// can be searched and fetched like any other card. Must run after
+// ResolveParts.
+func BuildTokens(db []cards.Card) ([]cards.Card, []cards.CatalogToken) {
+	tokenCards := indexTokenCards(db)
+	catalogue := map[string]*cards.CatalogToken{}
+	creators := len(db)
+
+	for i := 0; i < creators; i++ {
+		for _, t := range db[i].Tokens {
+			id := cards.TokenID(t)
+			j, isCard := tokenCards.find(t)
+
+			if isCard {
+				id = db[j].ID
+			}
+
+			entry, ok := catalogue[id]
+			if !ok {
+				if isCard {
+					entry = catalogueEntry(&db[j])
+				} else {
+					db = append(db, tokenCard(t, id))
+					entry = catalogueEntry(&db[len(db)-1])
+				}
+				entry.Image = strings.TrimSpace(t.Image)
+				catalogue[id] = entry
+			}
+
+			entry.CreatedBy = addPart(entry.CreatedBy, cardPart(&db[i], cards.ComponentComboPiece))
+		}
+	}
+
+	// Link the new token cards back to the cards creating them
+	for i := creators; i < len(db); i++ {
+		db[i].AllParts = append([]cards.RelatedCard{cardPart(&db[i], cards.ComponentToken)}, catalogue[db[i].ID].CreatedBy...)
+	}
+
+	// Token cards nothing creates are still tokens
+	for i := 0; i < creators; i++ {
+		if _, ok := catalogue[db[i].ID]; ok || !isToken(&db[i]) {
+			continue
+		}
+
+		catalogue[db[i].ID] = catalogueEntry(&db[i])
+	}
+
+	tokens := make([]cards.CatalogToken, 0, len(catalogue))
+	for _, entry := range catalogue {
+		tokens = append(tokens, *entry)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].Name != tokens[j].Name {
+			return tokens[i].Name < tokens[j].Name
+		}
+		return tokens[i].ID < tokens[j].ID
+	})
+
+	return db, tokens
+}
+
+// tokenCard builds a card out of a token listed on the card creating it.
+func tokenCard(t cards.Token, id string) cards.Card {
+	isToken := true
+	typeLine := strings.TrimSpace(t.Type)
+	types, subtypes, _ := strings.Cut(typeLine, strings.TrimSpace(cards.TypeLineSeparator))
+
+	supertypes := []string{}
+	cardTypes := []string{}
+
+	for _, word := range strings.Fields(types) {
+		if _, ok := supertypeVocabulary[strings.ToLower(word)]; ok {
+			supertypes = append(supertypes, word)
+		} else {
+			cardTypes = append(cardTypes, word)
+		}
+	}
+
+	side := cards.Side{
+		Supertypes: normaliseList(supertypes, supertypeVocabulary),
+		CardTypes:  normaliseList(cardTypes, cardTypeVocabulary),
+		Subtypes:   normaliseList(strings.Fields(subtypes), nil),
+		TypeLine:   typeLine,
+		ImageURIs:  imageURIs(&t.Image),
+	}
+
+	side.PowerOriginal, side.Power = tokenStat(t.Power)
+	side.ToughnessOriginal, side.Toughness = tokenStat(t.Toughness)
+
+	return cards.Card{
+		ID:            id,
+		Name:          strings.TrimSpace(t.Name),
+		Legality:      []string{},
+		Colors:        []string{},
+		TypeLine:      typeLine,
+		Keywords:      []string{},
+		Sides:         []cards.Side{side},
+		ImageURIs:     imageURIs(&t.Image),
+		Tags:          []string{},
+		IsActualToken: &isToken,
+	}
+}
+
+func tokenStat(value string) (*string, *float64) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return &value, &n
+	}
+
+	return &value, nil
+}
+
+func catalogueEntry(c *cards.Card) *cards.CatalogToken {
+	entry := &cards.CatalogToken{
+		Object:    "token",
+		ID:        c.ID,
+		Name:      c.Name,
+		TypeLine:  c.TypeLine,
+		CreatedBy: []cards.RelatedCard{},
+	}
+
+	if len(c.Sides) > 0 {
+		entry.Power = utils.Coalesce(c.Sides[0].PowerOriginal, "")
+		entry.Toughness = utils.Coalesce(c.Sides[0].ToughnessOriginal, "")
+	}
+
+	return entry
+}
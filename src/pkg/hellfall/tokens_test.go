@@ -0,0 +1,119 @@
+package hellfall
+
+import (
+	"hf-api/src/pkg/cards"
+	"slices"
+	"testing"
+)
+
+// buildTokens runs the gendb steps up to the token catalogue.
+func buildTokens(t *testing.T, db []cards.Card) ([]cards.Card, []cards.CatalogToken) {
+	t.Helper()
+
+	AssignIDs(db)
+	if warnings := ResolveParts(db); len(warnings) > 0 {
+		t.Fatalf("ResolveParts warned %v", warnings)
+	}
+
+	return BuildTokens(db)
+}
+
+func creatorIDs(token cards.CatalogToken) []string {
+	ids := []string{}
+	for _, part := range token.CreatedBy {
+		ids = append(ids, part.ID)
+	}
+	return ids
+}
+
+func TestBuildTokensMergesCreators(t *testing.T) {
+	treasure := cards.Token{Name: "Treasure", Type: "Token Artifact — Treasure", Image: "https://example.com/treasure.png"}
+	goblin := cards.Token{Name: "Goblin", Type: "Token Creature — Goblin", Power: "1", Toughness: "1"}
+	bigGoblin := cards.Token{Name: "Goblin", Type: "Token Creature — Goblin", Power: "2", Toughness: "2"}
+
+	db, tokens := buildTokens(t, []cards.Card{
+		{Name: "Pirate", Tokens: []cards.Token{treasure}},
+		{Name: "Goblin Maker", Tokens: []cards.Token{goblin, treasure}},
+		// Padded the way the sheet sometimes has it
+		{Name: "Goblin Boss", Tokens: []cards.Token{{Name: " Goblin ", Type: "Token Creature — Goblin ", Power: "1", Toughness: "1"}, bigGoblin}},
+	})
+
+	pirate, maker, boss := db[0], db[1], db[2]
+
+	if len(tokens) != 3 || len(db) != 6 {
+		t.Fatalf("got %d tokens and %d cards, want 3 tokens with a card each", len(tokens), len(db))
+	}
+
+	byID := map[string]cards.CatalogToken{}
+	for _, token := range tokens {
+		byID[token.ID] = token
+	}
+
+	tests := []struct {
+		token    cards.Token
+		creators []string
+	}{
+		{treasure, []string{pirate.ID, maker.ID}},
+		{goblin, []string{maker.ID, boss.ID}},
+		{bigGoblin, []string{boss.ID}},
+	}
+
+	for _, tt := range tests {
+		id := cards.TokenID(tt.token)
+		token, ok := byID[id]
+
+		if !ok {
+			t.Errorf("no %s/%s %s in the catalogue", tt.token.Power, tt.token.Toughness, tt.token.Name)
+			continue
+		}
+
+		if got := creatorIDs(token); !slices.Equal(got, tt.creators) {
+			t.Errorf("%s %s/%s is created by %v, want %v", token.Name, token.Power, token.Toughness, got, tt.creators)
+		}
+
+		// Its card links back to the same creators
+		i := slices.IndexFunc(db, func(c cards.Card) bool { return c.ID == id })
+		if i < 3 {
+			t.Errorf("%s has no card of its own", token.Name)
+			continue
+		}
+
+		if got := partIDs(&db[i]); !slices.Equal(got, append([]string{id}, tt.creators...)) {
+			t.Errorf("all_parts of the %s card = %v", token.Name, got)
+		}
+	}
+
+	if got := byID[cards.TokenID(treasure)].Image; got != treasure.Image {
+		t.Errorf("treasure's image is %q", got)
+	}
+}
+
+func TestBuildTokensKeepsTokenCards(t *testing.T) {
+	isToken := true
+
+	db, tokens := buildTokens(t, []cards.Card{
+		{Name: "Goblin Maker", Tokens: []cards.Token{{Name: "Goblin", Type: "Token Creature — Goblin"}}},
+		{Name: "Goblin", TypeLine: "Token Creature — Goblin", IsActualToken: &isToken},
+		{Name: "Angel", TypeLine: "Token Creature — Angel", IsActualToken: &isToken},
+	})
+
+	// The goblin on the sheet is the token; no card is added for it
+	if len(db) != 3 {
+		t.Fatalf("got %d cards, want the 3 on the sheet", len(db))
+	}
+
+	if len(tokens) != 2 {
+		t.Fatalf("got %d tokens, want the goblin and the angel", len(tokens))
+	}
+
+	angel, goblin := tokens[0], tokens[1]
+
+	if goblin.ID != db[1].ID || !slices.Equal(creatorIDs(goblin), []string{db[0].ID}) {
+		t.Errorf("goblin token is %+v", goblin)
+	}
+
+	// Tokens nothing creates are still listed
+	if angel.ID != db[2].ID || len(angel.CreatedBy) != 0 {
+		t.Errorf("angel token is %+v", angel)
+	}
+}